@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CachePolicy sets how long the api responses are kept.
+type CachePolicy struct {
+	TTL      time.Duration // Time a response is served without asking the api again, no caching when 0
+	MaxStale time.Duration // Time past the TTL a response is still served when the api fails, ignored when TTL is 0
+}
+
+// cache keeps the raw api responses by path, so every caller decodes its own
+// copy. Concurrent lookups of the same path
+// share a single request, and expired responses are served while the api
+// fails to refresh them.
+type cache struct {
+	policy func() CachePolicy
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	calls   map[string]*cacheCall
+
+	hits      int64
+	misses    int64
+	coalesced int64
+	stale     int64
+	failures  int64
+}
+
+type cacheEntry struct {
+	value   []byte
+	fetched time.Time
+}
+
+// cacheCall is a request in flight, done is closed once it has finished.
+type cacheCall struct {
+	done  chan struct{}
+	entry cacheEntry
+	err   error
+}
+
+func newCache(policy func() CachePolicy) *cache {
+	if policy == nil {
+		policy = func() CachePolicy { return CachePolicy{} }
+	}
+	return &cache{
+		policy:  policy,
+		entries: make(map[string]cacheEntry),
+		calls:   make(map[string]*cacheCall),
+	}
+}
+
+// get returns the response of key, fetching it when it is not cached or has
+// expired, along with the time it was fetched.
+func (c *cache) get(ctx context.Context, key string, fetch func(ctx context.Context) ([]byte, error)) ([]byte, time.Time, error) {
+	policy := c.policy()
+
+	c.mu.Lock()
+	entry, cached := c.entries[key]
+	if policy.TTL <= 0 {
+		// Caching is disabled, the responses kept before are not served
+		// either, even when the api fails
+		delete(c.entries, key)
+		cached = false
+	}
+	if cached && time.Since(entry.fetched) < policy.TTL {
+		c.mu.Unlock()
+		atomic.AddInt64(&c.hits, 1)
+		return entry.value, entry.fetched, nil
+	}
+
+	call, inFlight := c.calls[key]
+	if inFlight {
+		atomic.AddInt64(&c.coalesced, 1)
+	} else {
+		atomic.AddInt64(&c.misses, 1)
+		call = &cacheCall{done: make(chan struct{})}
+		c.calls[key] = call
+		// The request is shared, so it is not cancelled along with the
+		// context of the caller starting it
+		go c.fetch(detach(ctx), key, policy.TTL > 0, call, fetch)
+	}
+	c.mu.Unlock()
+
+	var err error
+	select {
+	case <-call.done:
+		if err = call.err; err == nil {
+			return call.entry.value, call.entry.fetched, nil
+		}
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	// Better old stats than no stats
+	if cached && time.Since(entry.fetched) < policy.TTL+policy.MaxStale {
+		atomic.AddInt64(&c.stale, 1)
+		return entry.value, entry.fetched, nil
+	}
+	return nil, time.Time{}, err
+}
+
+// fetch asks the api for key and shares the response with the callers of
+// call, keeping it when store is set.
+func (c *cache) fetch(ctx context.Context, key string, store bool, call *cacheCall, fetch func(ctx context.Context) ([]byte, error)) {
+	value, err := fetch(ctx)
+
+	c.mu.Lock()
+	if err == nil {
+		call.entry = cacheEntry{value: value, fetched: time.Now()}
+		if store {
+			c.entries[key] = call.entry
+		}
+	} else {
+		atomic.AddInt64(&c.failures, 1)
+		call.err = err
+	}
+	delete(c.calls, key)
+	c.mu.Unlock()
+
+	close(call.done)
+}
+
+// Var returns an expvar variable exposing the cache metrics.
+func (c *cache) Var() expvar.Var {
+	return expvar.Func(func() interface{} {
+		c.mu.Lock()
+		entries := len(c.entries)
+		c.mu.Unlock()
+		return map[string]interface{}{
+			"entries":   entries,
+			"hits":      atomic.LoadInt64(&c.hits),
+			"misses":    atomic.LoadInt64(&c.misses),
+			"coalesced": atomic.LoadInt64(&c.coalesced),
+			"stale":     atomic.LoadInt64(&c.stale),
+			"failures":  atomic.LoadInt64(&c.failures),
+		}
+	})
+}
+
+// detachedContext keeps the values of its parent but not its cancellation.
+type detachedContext struct {
+	context.Context
+}
+
+func detach(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheCoalesces(t *testing.T) {
+	c := newCache(func() CachePolicy { return CachePolicy{TTL: time.Minute} })
+
+	var fetches int32
+	release := make(chan struct{})
+	fetch := func(ctx context.Context) ([]byte, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return []byte("servers"), nil
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, _, err := c.get(context.Background(), "servers", fetch)
+			if err != nil || string(value) != "servers" {
+				t.Errorf("got %q, %v", value, err)
+			}
+		}()
+	}
+	// Every caller waits on the same request before it finishes
+	for atomic.LoadInt64(&c.misses)+atomic.LoadInt64(&c.coalesced) < callers {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if _, _, err := c.get(context.Background(), "servers", fetch); err != nil {
+		t.Fatal(err)
+	}
+	if fetches != 1 {
+		t.Errorf("%d fetches, want 1", fetches)
+	}
+	if c.coalesced != callers-1 || c.hits != 1 {
+		t.Errorf("%d coalesced and %d hits, want %d and 1", c.coalesced, c.hits, callers-1)
+	}
+}
+
+func TestCacheStale(t *testing.T) {
+	const ttl, maxStale = time.Minute, 5 * time.Minute
+	errDown := errors.New("api down")
+
+	tests := []struct {
+		name    string
+		age     time.Duration // Of the cached response
+		fetch   error         // Error of the fetch
+		want    string
+		wantErr error
+		fetched bool // Whether the api was asked
+	}{
+		{"fresh", time.Second, nil, "cached", nil, false},
+		{"expired", ttl + time.Second, nil, "fetched", nil, true},
+		{"stale", ttl + time.Minute, errDown, "cached", nil, true},
+		{"too stale", ttl + maxStale + time.Second, errDown, "", errDown, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newCache(func() CachePolicy { return CachePolicy{TTL: ttl, MaxStale: maxStale} })
+			c.entries["servers"] = cacheEntry{value: []byte("cached"), fetched: time.Now().Add(-test.age)}
+
+			fetched := false
+			value, _, err := c.get(context.Background(), "servers", func(ctx context.Context) ([]byte, error) {
+				fetched = true
+				if test.fetch != nil {
+					return nil, test.fetch
+				}
+				return []byte("fetched"), nil
+			})
+			if string(value) != test.want || err != test.wantErr {
+				t.Errorf("got %q, %v, want %q, %v", value, err, test.want, test.wantErr)
+			}
+			if fetched != test.fetched {
+				t.Errorf("api asked %v, want %v", fetched, test.fetched)
+			}
+		})
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	c := newCache(func() CachePolicy { return CachePolicy{MaxStale: time.Hour} })
+	c.entries["servers"] = cacheEntry{value: []byte("cached"), fetched: time.Now()}
+
+	errDown := errors.New("api down")
+	var fetches int
+	fetch := func(ctx context.Context) ([]byte, error) {
+		fetches++
+		if fetches == 2 {
+			return nil, errDown
+		}
+		return []byte("fetched"), nil
+	}
+
+	if value, _, err := c.get(context.Background(), "servers", fetch); string(value) != "fetched" || err != nil {
+		t.Errorf("got %q, %v, want the fetched response", value, err)
+	}
+	// Neither the fetched nor the old response is served when the api fails
+	if value, _, err := c.get(context.Background(), "servers", fetch); value != nil || err != errDown {
+		t.Errorf("got %q, %v, want %v", value, err, errDown)
+	}
+	if fetches != 2 {
+		t.Errorf("%d fetches, want 2", fetches)
+	}
+	if len(c.entries) != 0 {
+		t.Errorf("%d entries kept, want 0", len(c.entries))
+	}
+}
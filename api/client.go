@@ -0,0 +1,219 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hestingames/hg-hebe-bot/internal/apiclient"
+)
+
+const (
+	defaultTimeout   = 10 * time.Second
+	defaultUserAgent = "HebeBot (+https://hestingames.nat.cu)"
+)
+
+// StatusError is returned when the api answers with a non 2xx status, it
+// carries the status code and the beginning of the response body.
+type StatusError = apiclient.StatusError
+
+// ErrCircuitOpen is returned without calling the api while it is considered
+// down, after too many consecutive failures.
+var ErrCircuitOpen = apiclient.ErrCircuitOpen
+
+// TLSConfig sets how the api certificate is trusted and the client
+// certificate sent to it.
+type TLSConfig = apiclient.TLSOptions
+
+// Policy sets how the failed requests are retried and when the api is
+// considered down.
+type Policy = apiclient.Policy
+
+// Client talks to a CSGO api backend. Several clients can be used at the
+// same time against different backends.
+type Client struct {
+	baseUrl *url.URL
+	http    *apiclient.Client
+	cache   *cache
+}
+
+type options struct {
+	baseUrl   string
+	timeout   time.Duration
+	userAgent string
+	header    http.Header
+	transport http.RoundTripper
+	tls       *TLSConfig
+	policy    func() Policy
+	onRetry   func(url string, retry int, err error)
+	onDegrade func(degraded bool)
+	cache     func() CachePolicy
+}
+
+// Option configures a Client.
+type Option func(*options)
+
+// WithBaseURL sets the url the api paths are resolved against.
+func WithBaseURL(baseUrl string) Option {
+	return func(o *options) { o.baseUrl = baseUrl }
+}
+
+// WithTimeout sets the timeout of each request, 0 disables it.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) { o.timeout = timeout }
+}
+
+// WithUserAgent sets the User-Agent header of the requests.
+func WithUserAgent(userAgent string) Option {
+	return func(o *options) { o.userAgent = userAgent }
+}
+
+// WithBearerToken authenticates the requests with an Authorization header.
+func WithBearerToken(token string) Option {
+	return func(o *options) {
+		if token != "" {
+			o.header.Set("Authorization", "Bearer "+token)
+		}
+	}
+}
+
+// WithAPIKey authenticates the requests with the key in the given header.
+func WithAPIKey(header, key string) Option {
+	return func(o *options) {
+		if header != "" && key != "" {
+			o.header.Set(header, key)
+		}
+	}
+}
+
+// WithTransport sets the transport the requests are sent through.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(o *options) { o.transport = transport }
+}
+
+// WithTLS sets the TLS config of the client, on its own transport. It
+// cannot be combined with WithTransport.
+func WithTLS(config TLSConfig) Option {
+	return func(o *options) { o.tls = &config }
+}
+
+// WithPolicy sets the retry and circuit breaker policy. policy is called on
+// every request, so it can return live config values.
+func WithPolicy(policy func() Policy) Option {
+	return func(o *options) { o.policy = policy }
+}
+
+// WithRetryHook sets a function called before each retry of a request.
+func WithRetryHook(hook func(url string, retry int, err error)) Option {
+	return func(o *options) { o.onRetry = hook }
+}
+
+// WithDegradedHook sets a function called when the api is considered down,
+// and when it recovers.
+func WithDegradedHook(hook func(degraded bool)) Option {
+	return func(o *options) { o.onDegrade = hook }
+}
+
+// WithCache caches the api responses. policy is called on every request, so
+// it can return live config values.
+func WithCache(policy func() CachePolicy) Option {
+	return func(o *options) { o.cache = policy }
+}
+
+// IsDegraded reports whether err comes from the api being unavailable, as
+// opposed to a bad request.
+func IsDegraded(err error) bool {
+	return apiclient.Degraded(err)
+}
+
+// NewClient creates a client for the api at the base url.
+func NewClient(opts ...Option) (*Client, error) {
+	o := options{
+		timeout:   defaultTimeout,
+		userAgent: defaultUserAgent,
+		header:    make(http.Header),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	baseUrl, err := url.Parse(o.baseUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid api base url: %w", err)
+	}
+	if baseUrl.Scheme != "http" && baseUrl.Scheme != "https" || baseUrl.Host == "" {
+		return nil, errors.New("api base url must be an absolute http(s) url")
+	}
+	// Paths are resolved relative to the base url path
+	if !strings.HasSuffix(baseUrl.Path, "/") {
+		baseUrl.Path += "/"
+	}
+
+	if o.tls != nil {
+		if o.transport != nil {
+			return nil, errors.New("WithTLS and WithTransport cannot be combined")
+		}
+		transport, err := apiclient.NewTransport(*o.tls)
+		if err != nil {
+			return nil, err
+		}
+		o.transport = transport
+	}
+
+	clientOptions := apiclient.Options{
+		Timeout:   o.timeout,
+		UserAgent: o.userAgent,
+		Header:    o.header,
+		Transport: o.transport,
+		Policy:    o.policy,
+	}
+	if o.onRetry != nil {
+		clientOptions.OnRetry = func(verb, url string, retry int, err error) { o.onRetry(url, retry, err) }
+	}
+	if o.onDegrade != nil {
+		clientOptions.OnBreakerChange = func(host string, open bool) { o.onDegrade(open) }
+	}
+
+	return &Client{
+		baseUrl: baseUrl,
+		http:    apiclient.New(clientOptions),
+		cache:   newCache(o.cache),
+	}, nil
+}
+
+// Degraded reports whether the api is considered down.
+func (c *Client) Degraded() bool {
+	return c.http.Degraded()
+}
+
+// BaseURL returns the url the api paths are resolved against.
+func (c *Client) BaseURL() string {
+	return c.baseUrl.String()
+}
+
+// CacheVar returns an expvar variable exposing the cache metrics.
+func (c *Client) CacheVar() expvar.Var {
+	return c.cache.Var()
+}
+
+// get decodes the JSON response of the api path into v and returns when it
+// was fetched, which is in the past for cached responses.
+func (c *Client) get(ctx context.Context, path string, v interface{}) (time.Time, error) {
+	endpoint := c.baseUrl.ResolveReference(&url.URL{Path: path}).String()
+	bytes, fetched, err := c.cache.get(ctx, path, func(ctx context.Context) ([]byte, error) {
+		return c.http.Do(ctx, http.MethodGet, endpoint)
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := json.Unmarshal(bytes, v); err != nil {
+		return time.Time{}, fmt.Errorf("unable to decode %s: %w", endpoint, err)
+	}
+	return fetched, nil
+}
@@ -1,53 +1,28 @@
 package api
 
 import (
-	"encoding/json"
-	"fmt"
-
-	"github.com/hestingames/hg-hebe-bot/internal/apiclient"
-)
-
-var (
-	ApiBaseUrl string
+	"context"
+	"time"
 )
 
-func InitializeCsgoApi(BaseUrl string) {
-	ApiBaseUrl = BaseUrl
-	apiclient.InitializeApiClient()
-
-	// Development api is using a self signed certificate
-	apiclient.DisableCertificateCheck()
-}
-
-func GetMatchakingQueueStatus() ([]MatmakingQueueStatus, error) {
+func (c *Client) GetMatchmakingQueueStatus(ctx context.Context) ([]MatmakingQueueStatus, time.Time, error) {
 	var queueStatus []MatmakingQueueStatus
-	bytes, err := apiclient.DoRequest("GET", fmt.Sprintf("%squery/queues", ApiBaseUrl))
-	if err != nil {
-		return queueStatus, err
-	}
-
-	err = json.Unmarshal(bytes, &queueStatus)
-	return queueStatus, err
+	fetched, err := c.get(ctx, "query/queues", &queueStatus)
+	return queueStatus, fetched, err
 }
 
-func GetPlayingNow() (int, error) {
-	playingNow := 0
+func (c *Client) GetServers(ctx context.Context) ([]CsgoServer, time.Time, error) {
 	var csgoServers CsgoServersResponse
-	bytes, err := apiclient.DoRequest("GET", fmt.Sprintf("%squery/servers", ApiBaseUrl))
-	if err != nil {
-		return playingNow, err
-	}
+	fetched, err := c.get(ctx, "query/servers", &csgoServers)
+	return csgoServers.Servers, fetched, err
+}
 
-	err = json.Unmarshal(bytes, &csgoServers)
+func (c *Client) GetPlayingNow(ctx context.Context) (int, time.Time, error) {
+	servers, fetched, err := c.GetServers(ctx)
 	if err != nil {
-		return playingNow, err
+		return 0, fetched, err
 	}
-
-	for i := range csgoServers.Servers {
-		playingNow += len(csgoServers.Servers[i].PlayersId)
-	}
-
-	return playingNow, err
+	return playersOn(servers), fetched, nil
 }
 
 func ParseServerStatus(matchmakingStatus []MatmakingQueueStatus) CsgoServerStatus {
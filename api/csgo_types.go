@@ -40,3 +40,17 @@ const (
 	Delta    GameType = 269530119
 	Sigma    GameType = 1635794951
 )
+
+func (g GameType) String() string {
+	switch g {
+	case DustII:
+		return "Dust II"
+	case Hostages:
+		return "Hostages"
+	case Delta:
+		return "Delta"
+	case Sigma:
+		return "Sigma"
+	}
+	return "Desconocido"
+}
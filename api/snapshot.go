@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WarningKind is the kind of inconsistency found between the servers and
+// the matchmaking queues.
+type WarningKind string
+
+const (
+	// The players on the servers and the players of the queues differ
+	PlayersMismatch WarningKind = "playersMismatch"
+	// The players on the servers of a queue and the players of the queue differ
+	QueuePlayersMismatch WarningKind = "queuePlayersMismatch"
+	// A queue lists a server that is not online
+	UnknownQueueServer WarningKind = "unknownQueueServer"
+	// A queue lists a server of another game type
+	QueueServerGameType WarningKind = "queueServerGameType"
+	// A matchmaking server is not listed by the queue of its game type
+	UnlistedServer WarningKind = "unlistedServer"
+)
+
+// Warning is an inconsistency between the servers and the matchmaking queues.
+type Warning struct {
+	Kind     WarningKind
+	GameType GameType // Zero for the whole service
+	ServerId int64    // Zero when not about a server
+	Servers  int      // Count from the servers
+	Queues   int      // Count from the queues
+}
+
+func (w Warning) String() string {
+	switch w.Kind {
+	case PlayersMismatch:
+		return fmt.Sprintf("%d players on the servers but %d in the queues", w.Servers, w.Queues)
+	case QueuePlayersMismatch:
+		return fmt.Sprintf("%d players on the %s servers but %d in its queue", w.Servers, w.GameType, w.Queues)
+	case UnknownQueueServer:
+		return fmt.Sprintf("%s queue lists server %d, which is not online", w.GameType, w.ServerId)
+	case QueueServerGameType:
+		return fmt.Sprintf("%s queue lists server %d of another game type", w.GameType, w.ServerId)
+	case UnlistedServer:
+		return fmt.Sprintf("matchmaking server %d is not listed by the %s queue", w.ServerId, w.GameType)
+	}
+	return string(w.Kind)
+}
+
+// StatusSnapshot is the state of the service from the servers and the
+// matchmaking queues fetched at the same time. Any of them may be missing,
+// along with the error retrieving it.
+type StatusSnapshot struct {
+	Servers    []CsgoServer
+	ServersErr error
+	Queues     []MatmakingQueueStatus
+	QueuesErr  error
+
+	PlayingNow int              // From the servers, or the queues when the servers are missing
+	Status     CsgoServerStatus // From the queues, zero when they are missing
+	Fetched    time.Time        // Fetch time of the oldest data
+	Warnings   []Warning        // Inconsistencies, only checked when both are present
+}
+
+// Complete reports whether both the servers and the queues were retrieved.
+func (s StatusSnapshot) Complete() bool {
+	return s.ServersErr == nil && s.QueuesErr == nil
+}
+
+// Consistent reports whether the servers and the queues agree.
+func (s StatusSnapshot) Consistent() bool {
+	return len(s.Warnings) == 0
+}
+
+// GetStatusSnapshot fetches the servers and the matchmaking queues
+// concurrently and checks them against each other. The error is only
+// returned when neither could be retrieved, partial snapshots carry the
+// error of the missing part.
+func (c *Client) GetStatusSnapshot(ctx context.Context) (StatusSnapshot, error) {
+	var (
+		snapshot                      StatusSnapshot
+		serversFetched, queuesFetched time.Time
+		wg                            sync.WaitGroup
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		snapshot.Servers, serversFetched, snapshot.ServersErr = c.GetServers(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		snapshot.Queues, queuesFetched, snapshot.QueuesErr = c.GetMatchmakingQueueStatus(ctx)
+	}()
+	wg.Wait()
+
+	switch {
+	case snapshot.ServersErr != nil && snapshot.QueuesErr != nil:
+		return snapshot, snapshot.ServersErr
+	case snapshot.ServersErr != nil:
+		snapshot.Status = ParseServerStatus(snapshot.Queues)
+		snapshot.PlayingNow = int(snapshot.Status.PlayingNow)
+		snapshot.Fetched = queuesFetched
+	case snapshot.QueuesErr != nil:
+		snapshot.PlayingNow = playersOn(snapshot.Servers)
+		snapshot.Fetched = serversFetched
+	default:
+		snapshot.Status = ParseServerStatus(snapshot.Queues)
+		snapshot.PlayingNow = playersOn(snapshot.Servers)
+		snapshot.Fetched = serversFetched
+		if queuesFetched.Before(serversFetched) {
+			snapshot.Fetched = queuesFetched
+		}
+		snapshot.Warnings = reconcile(snapshot.Servers, snapshot.Queues)
+	}
+	return snapshot, nil
+}
+
+// reconcile checks the servers each queue lists and the players of the
+// queues against the servers.
+func reconcile(servers []CsgoServer, queues []MatmakingQueueStatus) []Warning {
+	var warnings []Warning
+
+	byId := make(map[int64]CsgoServer, len(servers))
+	for _, server := range servers {
+		byId[server.ServerId] = server
+	}
+
+	listed := make(map[int64]bool)
+	queuePlayers := 0
+	for _, queue := range queues {
+		gameType := GameType(queue.GameType)
+		queuePlayers += queue.CurrentPlaying
+
+		serverPlayers := 0
+		for _, id := range queue.ServerList {
+			listed[id] = true
+			server, ok := byId[id]
+			switch {
+			case !ok:
+				warnings = append(warnings, Warning{Kind: UnknownQueueServer, GameType: gameType, ServerId: id})
+				continue
+			case server.GameType != queue.GameType:
+				warnings = append(warnings, Warning{Kind: QueueServerGameType, GameType: gameType, ServerId: id})
+			}
+			serverPlayers += len(server.PlayersId)
+		}
+		if serverPlayers != queue.CurrentPlaying {
+			warnings = append(warnings, Warning{
+				Kind:     QueuePlayersMismatch,
+				GameType: gameType,
+				Servers:  serverPlayers,
+				Queues:   queue.CurrentPlaying,
+			})
+		}
+	}
+
+	queued := make(map[int]bool, len(queues))
+	for _, queue := range queues {
+		queued[queue.GameType] = true
+	}
+	for _, server := range servers {
+		// Servers of game types without a queue are not expected in any
+		if server.MatchmakingServer && queued[server.GameType] && !listed[server.ServerId] {
+			warnings = append(warnings, Warning{Kind: UnlistedServer, GameType: GameType(server.GameType), ServerId: server.ServerId})
+		}
+	}
+
+	if players := playersOn(servers); players != queuePlayers {
+		warnings = append(warnings, Warning{Kind: PlayersMismatch, Servers: players, Queues: queuePlayers})
+	}
+	return warnings
+}
+
+func playersOn(servers []CsgoServer) int {
+	players := 0
+	for i := range servers {
+		players += len(servers[i].PlayersId)
+	}
+	return players
+}
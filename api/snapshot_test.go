@@ -0,0 +1,76 @@
+package api
+
+import "testing"
+
+func TestReconcile(t *testing.T) {
+	const (
+		competitive = 519
+		casual      = 135200775
+	)
+	server := func(id int64, gameType, players int) CsgoServer {
+		return CsgoServer{ServerId: id, GameType: gameType, PlayersId: make([]int64, players), MatchmakingServer: true}
+	}
+	queue := func(gameType, playing int, servers ...int64) MatmakingQueueStatus {
+		return MatmakingQueueStatus{GameType: gameType, CurrentPlaying: playing, ServerList: servers}
+	}
+
+	tests := []struct {
+		name    string
+		servers []CsgoServer
+		queues  []MatmakingQueueStatus
+		want    []WarningKind
+	}{
+		{
+			name:    "consistent",
+			servers: []CsgoServer{server(1, competitive, 3), server(2, casual, 1)},
+			queues:  []MatmakingQueueStatus{queue(competitive, 3, 1), queue(casual, 1, 2)},
+		},
+		{
+			name:    "queue players mismatch",
+			servers: []CsgoServer{server(1, competitive, 3), server(2, casual, 1)},
+			queues:  []MatmakingQueueStatus{queue(competitive, 2, 1), queue(casual, 2, 2)},
+			want:    []WarningKind{QueuePlayersMismatch, QueuePlayersMismatch},
+		},
+		{
+			name:    "players mismatch",
+			servers: []CsgoServer{server(1, competitive, 3), {ServerId: 2, GameType: casual, PlayersId: make([]int64, 2)}},
+			queues:  []MatmakingQueueStatus{queue(competitive, 3, 1)},
+			want:    []WarningKind{PlayersMismatch},
+		},
+		{
+			name:    "unknown queue server",
+			servers: []CsgoServer{server(1, competitive, 3)},
+			queues:  []MatmakingQueueStatus{queue(competitive, 3, 1, 9)},
+			want:    []WarningKind{UnknownQueueServer},
+		},
+		{
+			name:    "queue server game type",
+			servers: []CsgoServer{server(1, competitive, 3), server(2, casual, 0)},
+			queues:  []MatmakingQueueStatus{queue(competitive, 3, 1, 2), queue(casual, 0)},
+			want:    []WarningKind{QueueServerGameType},
+		},
+		{
+			name:    "unlisted server",
+			servers: []CsgoServer{server(1, competitive, 3), server(2, competitive, 0)},
+			queues:  []MatmakingQueueStatus{queue(competitive, 3, 1)},
+			want:    []WarningKind{UnlistedServer},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			warnings := reconcile(test.servers, test.queues)
+			var got []WarningKind
+			for _, warning := range warnings {
+				got = append(got, warning.Kind)
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("got warnings %v, want %v", warnings, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Fatalf("got warnings %v, want %v", warnings, test.want)
+				}
+			}
+		})
+	}
+}
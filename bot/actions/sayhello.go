@@ -1,10 +1,11 @@
 package actions
 
 import (
+	"context"
 	"fmt"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
 	"github.com/hestingames/hg-hebe-bot/internal/logs"
 )
 
@@ -14,8 +15,8 @@ const (
 		"🤝 Por favor respete las reglas (/rules) del grupo."
 )
 
-func SayHello(logger *logs.Logger, hebeBot tgbotapi.BotAPI, chatId int64, user tgbotapi.User) {
-	hebeBot.Send(tgbotapi.NewChatAction(chatId, tgbotapi.ChatTyping))
+func SayHello(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, chatId int64, user tgbotapi.User) {
+	hebeBot.Enqueue(tgbotapi.NewChatAction(chatId, tgbotapi.ChatTyping))
 
 	// Use user username in welcome message if have one
 	name := user.FirstName
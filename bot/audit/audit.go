@@ -0,0 +1,268 @@
+// Package audit keeps the log of every moderation action, whether an admin
+// took it through the bot or it was taken automatically, and optionally
+// mirrors each entry to a staff channel.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/config"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+	"github.com/hestingames/hg-hebe-bot/internal/store"
+	"go.uber.org/zap"
+)
+
+// Store key of the audit log kept by older versions in the bot state
+const entriesKey = "audit.entries"
+
+// Suffix of the previous audit log file, kept after a rotation
+const rotatedSuffix = ".1"
+
+var errNotInitialized = errors.New("audit log not initialized")
+
+// Entry of the audit log
+type Entry struct {
+	ID         int64
+	Time       time.Time
+	ChatID     int64
+	Action     string
+	ActorID    int64
+	ActorName  string
+	TargetID   int64 // Zero for actions on the whole chat
+	TargetName string
+	Reason     string
+	Until      time.Time // Expiry of temporary bans and mutes
+}
+
+// Labels of the actions shown to the admins
+var actionLabels = map[string]string{
+	"ban":        "🔨 Baneo",
+	"kick":       "👢 Expulsión",
+	"mute":       "🔇 Silencio",
+	"unmute":     "🔊 Fin del silencio",
+	"unban":      "✅ Fin del baneo",
+	"warn":       "⚠️ Advertencia",
+	"resetwarns": "♻️ Advertencias eliminadas",
+	"delete":     "🗑 Mensaje eliminado",
+	"lockdown":   "🚨 Bloqueo del grupo",
+	"unlock":     "🔓 Fin del bloqueo",
+}
+
+// The audit log is a file with an entry per line, only ever appended to.
+// Once it reaches the retention limit it is rotated, replacing the previous one
+var (
+	file      *os.File
+	filePath  string
+	count     int   // Entries in the current file
+	lastID    int64 // ID of the last entry recorded
+	entriesMu sync.Mutex
+)
+
+// Initialize opens the file the audit log is appended to. The entries older
+// versions kept in the bot state are moved to it.
+func Initialize(path string, st *store.Store) error {
+	entriesMu.Lock()
+	defer entriesMu.Unlock()
+	if err := open(path); err != nil {
+		return err
+	}
+
+	var old []Entry
+	if ok, err := st.Get(entriesKey, &old); err != nil || !ok {
+		return err
+	}
+	for i := range old {
+		if err := add(&old[i], 0); err != nil {
+			return err
+		}
+	}
+	return st.Delete(entriesKey)
+}
+
+// Close closes the audit log file.
+func Close() error {
+	entriesMu.Lock()
+	defer entriesMu.Unlock()
+	if file == nil {
+		return nil
+	}
+	err := file.Close()
+	file = nil
+	return err
+}
+
+// Record adds the entry to the audit log, and mirrors it to the staff
+// channel when one is configured.
+func Record(logger *logs.Logger, hebeBot botapi.Bot, entry Entry) {
+	entriesMu.Lock()
+	err := add(&entry, int(config.AppConfig.AuditMaxEntries.Get()))
+	entriesMu.Unlock()
+	if err != nil {
+		logger.Error("Unable to record audit entry", zap.String("action", entry.Action), zap.Error(err))
+	}
+
+	if channel := config.AppConfig.AuditChannel.Get(); channel != 0 {
+		hebeBot.Enqueue(tgbotapi.NewMessage(channel, Format(entry)))
+	}
+}
+
+// Query returns the entries matching filter, newest first.
+func Query(filter func(Entry) bool) ([]Entry, error) {
+	entriesMu.Lock()
+	defer entriesMu.Unlock()
+	if file == nil {
+		return nil, errNotInitialized
+	}
+
+	var matching []Entry
+	for _, path := range []string{filePath, filePath + rotatedSuffix} {
+		list, err := read(path)
+		if err != nil {
+			return nil, err
+		}
+		for i := len(list) - 1; i >= 0; i-- {
+			if filter(list[i]) {
+				matching = append(matching, list[i])
+			}
+		}
+	}
+	return matching, nil
+}
+
+// Format formats the entry for the admins.
+func Format(entry Entry) string {
+	label, ok := actionLabels[entry.Action]
+	if !ok {
+		label = entry.Action
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s · %s\n", label, chatName(entry.ChatID))
+	if entry.TargetID != 0 {
+		fmt.Fprintf(&text, "👤 %s [%d]\n", entry.TargetName, entry.TargetID)
+	}
+	fmt.Fprintf(&text, "👮🏻 %s\n", entry.ActorName)
+	if entry.Reason != "" {
+		fmt.Fprintf(&text, "📝 %s\n", entry.Reason)
+	}
+	if !entry.Until.IsZero() {
+		fmt.Fprintf(&text, "⏳ Hasta %s\n", entry.Until.Format("02/01/2006 15:04"))
+	}
+	fmt.Fprintf(&text, "🕒 %s", entry.Time.Format("02/01/2006 15:04"))
+	return text.String()
+}
+
+// open opens the audit log file at path, picking up where the entries in
+// it left. Must be called with entriesMu held.
+func open(path string) error {
+	current, err := read(path)
+	if err != nil {
+		return err
+	}
+	rotated, err := read(path + rotatedSuffix)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		file.Close()
+	}
+	file, filePath, count, lastID = f, path, len(current), 0
+	if len(current) != 0 {
+		lastID = current[len(current)-1].ID
+	} else if len(rotated) != 0 {
+		lastID = rotated[len(rotated)-1].ID
+	}
+	return nil
+}
+
+// add appends the entry to the file, rotating it first when it holds max
+// entries. No rotation happens when max is 0. Must be called with entriesMu
+// held.
+func add(entry *Entry, max int) error {
+	if file == nil {
+		return errNotInitialized
+	}
+
+	if max > 0 && count >= max {
+		if err := rotate(); err != nil {
+			return err
+		}
+	}
+
+	// Entries moved from the bot state keep their ID
+	if entry.ID <= lastID {
+		entry.ID = lastID + 1
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	count++
+	lastID = entry.ID
+	return nil
+}
+
+// rotate replaces the previous file with the current one and starts a new
+// one. Must be called with entriesMu held.
+func rotate() error {
+	if err := os.Rename(filePath, filePath+rotatedSuffix); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	file.Close()
+	file, count = f, 0
+	return nil
+}
+
+// read returns the entries of the file at path, none when it does not
+// exist. A line cut short by a crash is skipped.
+func read(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var list []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil {
+			list = append(list, entry)
+		}
+	}
+	return list, scanner.Err()
+}
+
+func chatName(chatId int64) string {
+	if chat, ok := config.AppConfig.Chat(chatId); ok && chat.Name != "" {
+		return chat.Name
+	}
+	return fmt.Sprintf("%d", chatId)
+}
@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hestingames/hg-hebe-bot/internal/store"
+)
+
+func TestRotation(t *testing.T) {
+	dir := t.TempDir()
+	st, err := store.Open(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	path := filepath.Join(dir, "audit.jsonl")
+	if err := Initialize(path, st); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+
+	for i := 0; i < 5; i++ {
+		entriesMu.Lock()
+		err := add(&Entry{Action: "warn"}, 2)
+		entriesMu.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The fifth entry rotated the file holding the third and fourth, the
+	// first two are gone
+	assertIDs(t, 5, 4, 3)
+
+	// Reopening picks up the IDs where they were left
+	if err := Initialize(path, st); err != nil {
+		t.Fatal(err)
+	}
+	entriesMu.Lock()
+	err = add(&Entry{Action: "ban"}, 2)
+	entriesMu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertIDs(t, 6, 5, 4, 3)
+}
+
+func TestMigration(t *testing.T) {
+	dir := t.TempDir()
+	st, err := store.Open(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if err := st.Put(entriesKey, []Entry{{ID: 7, Action: "mute"}, {ID: 8, Action: "unmute"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Initialize(filepath.Join(dir, "audit.jsonl"), st); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+
+	assertIDs(t, 8, 7)
+	if ok, _ := st.Get(entriesKey, &[]Entry{}); ok {
+		t.Error("entries still in the bot state")
+	}
+}
+
+func assertIDs(t *testing.T, want ...int64) {
+	t.Helper()
+	list, err := Query(func(Entry) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int64
+	for _, entry := range list {
+		got = append(got, entry.ID)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got entries %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got entries %v, want %v", got, want)
+		}
+	}
+}
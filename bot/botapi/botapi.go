@@ -0,0 +1,78 @@
+package botapi
+
+import (
+	"encoding/json"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Bot is the part of the Telegram Bot API the bot relies on. Everything else
+// is built on top of Request, so handlers can run against any implementation.
+type Bot interface {
+	// Send sends c and returns the message it created.
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	// Request sends c and returns the raw response.
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	// Enqueue sends c without requiring the caller to wait for the result.
+	Enqueue(c tgbotapi.Chattable) <-chan Result
+
+	GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel
+	StopReceivingUpdates()
+
+	// Self is the bot user.
+	Self() tgbotapi.User
+}
+
+// Result of an enqueued request.
+type Result struct {
+	Message  tgbotapi.Message      // Sent message, if the request sent one
+	Response *tgbotapi.APIResponse // Raw Bot API response
+	Err      error
+}
+
+// Wrap adapts a BotAPI client to the Bot interface.
+func Wrap(api *tgbotapi.BotAPI) Bot {
+	return &botAPI{api: api}
+}
+
+type botAPI struct {
+	api *tgbotapi.BotAPI
+}
+
+func (b *botAPI) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return b.api.Send(c)
+}
+
+func (b *botAPI) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	return b.api.Request(c)
+}
+
+// Enqueue sends c right away, the BotAPI client has no queue.
+func (b *botAPI) Enqueue(c tgbotapi.Chattable) <-chan Result {
+	result := make(chan Result, 1)
+	resp, err := b.api.Request(c)
+	result <- NewResult(resp, err)
+	return result
+}
+
+func (b *botAPI) GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel {
+	return b.api.GetUpdatesChan(config)
+}
+
+func (b *botAPI) StopReceivingUpdates() {
+	b.api.StopReceivingUpdates()
+}
+
+func (b *botAPI) Self() tgbotapi.User {
+	return b.api.Self
+}
+
+// NewResult builds the result of a request, decoding the message it sent if any.
+func NewResult(resp *tgbotapi.APIResponse, err error) Result {
+	result := Result{Response: resp, Err: err}
+	// Only the requests sending a message get one back
+	if err == nil && resp != nil && len(resp.Result) > 0 && resp.Result[0] == '{' {
+		json.Unmarshal(resp.Result, &result.Message)
+	}
+	return result
+}
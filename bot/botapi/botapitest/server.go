@@ -0,0 +1,436 @@
+// Package botapitest provides an in-process fake of the Telegram Bot API to
+// run the bot handlers end to end without network access.
+//
+// The server records every request it receives and can inject updates that
+// are delivered through getUpdates:
+//
+//	server := botapitest.NewServer()
+//	defer server.Close()
+//
+//	bot, _ := server.Bot()
+//	server.InjectMessage(chat, user, "/rules")
+//	requests, ok := server.WaitRequests("sendMessage", 1, time.Second)
+package botapitest
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Token accepted by the server.
+const Token = "123456:fake-token"
+
+// maxPollTimeout caps the getUpdates long polling, so the tests are not held
+// by the long timeouts used in production.
+const maxPollTimeout = time.Second
+
+// Request is a Bot API call received by the server.
+type Request struct {
+	Method string
+	Params url.Values
+	Time   time.Time
+}
+
+// ChatID returns the chat_id parameter of the request.
+func (r Request) ChatID() int64 {
+	id, _ := strconv.ParseInt(r.Params.Get("chat_id"), 10, 64)
+	return id
+}
+
+// HandlerFunc builds the result of a Bot API method. Returning a
+// *tgbotapi.Error makes the server answer with that error code and
+// parameters.
+type HandlerFunc func(params url.Values) (interface{}, error)
+
+// Server is a fake Telegram Bot API server.
+type Server struct {
+	*httptest.Server
+
+	// Me is the bot user returned by getMe.
+	Me tgbotapi.User
+
+	mu            sync.Mutex
+	requests      []Request
+	updates       []tgbotapi.Update
+	nextUpdateID  int
+	nextMessageID int
+	handlers      map[string]HandlerFunc
+	files         map[string][]byte // Contents of the files by file_id
+	changed       chan struct{}     // Closed and replaced when a request or update arrives
+	quit          chan struct{}
+	closeOnce     sync.Once
+}
+
+// NewServer starts a fake Bot API server.
+func NewServer() *Server {
+	s := &Server{
+		Me:            tgbotapi.User{ID: 123456, IsBot: true, FirstName: "Hebe", UserName: "HebeTestBot"},
+		nextUpdateID:  1,
+		nextMessageID: 1,
+		handlers:      make(map[string]HandlerFunc),
+		files:         make(map[string][]byte),
+		changed:       make(chan struct{}),
+		quit:          make(chan struct{}),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Endpoint returns the api endpoint to give to tgbotapi.
+func (s *Server) Endpoint() string {
+	return s.URL + "/bot%s/%s"
+}
+
+// Bot returns a Bot API client talking to the server.
+func (s *Server) Bot() (*tgbotapi.BotAPI, error) {
+	return tgbotapi.NewBotAPIWithClient(Token, s.Endpoint(), s.Client())
+}
+
+// Close releases the pending long polls and shuts the server down.
+func (s *Server) Close() {
+	s.closeOnce.Do(func() { close(s.quit) })
+	s.Server.Close()
+}
+
+// Handle overrides the result of a Bot API method.
+func (s *Server) Handle(method string, handler HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = handler
+}
+
+// AddFile makes the contents downloadable as the file fileId, through
+// getFile and the file endpoint.
+func (s *Server) AddFile(fileId string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[fileId] = data
+}
+
+// InjectUpdate queues an update to be delivered by getUpdates. The update id
+// is assigned by the server.
+func (s *Server) InjectUpdate(update tgbotapi.Update) tgbotapi.Update {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	update.UpdateID = s.nextUpdateID
+	s.nextUpdateID++
+	s.updates = append(s.updates, update)
+	s.notify()
+	return update
+}
+
+// InjectMessage queues a text message sent by from to chat. Commands get
+// their bot_command entity, as Telegram does.
+func (s *Server) InjectMessage(chat tgbotapi.Chat, from tgbotapi.User, text string) tgbotapi.Update {
+	return s.InjectReply(chat, from, text, nil)
+}
+
+// InjectReply queues a text message sent by from to chat in reply to
+// another message, like InjectMessage.
+func (s *Server) InjectReply(chat tgbotapi.Chat, from tgbotapi.User, text string, replyTo *tgbotapi.Message) tgbotapi.Update {
+	message := &tgbotapi.Message{
+		MessageID:      s.newMessageID(),
+		From:           &from,
+		Chat:           &chat,
+		Date:           int(time.Now().Unix()),
+		Text:           text,
+		ReplyToMessage: replyTo,
+	}
+	if strings.HasPrefix(text, "/") {
+		length := strings.IndexByte(text, ' ')
+		if length < 0 {
+			length = len(text)
+		}
+		message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: length}}
+	}
+	return s.InjectUpdate(tgbotapi.Update{Message: message})
+}
+
+// InjectNewMembers queues the service message sent when users join chat.
+func (s *Server) InjectNewMembers(chat tgbotapi.Chat, members ...tgbotapi.User) tgbotapi.Update {
+	return s.InjectUpdate(tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID:      s.newMessageID(),
+		From:           &members[0],
+		Chat:           &chat,
+		Date:           int(time.Now().Unix()),
+		NewChatMembers: members,
+	}})
+}
+
+// Requests returns the recorded requests to the given methods, or every
+// request if no method is given.
+func (s *Server) Requests(methods ...string) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.filter(methods)
+}
+
+// WaitRequests waits until at least n requests to method were received or
+// timeout expires. It returns the requests and whether there were enough.
+func (s *Server) WaitRequests(method string, n int, timeout time.Duration) ([]Request, bool) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		s.mu.Lock()
+		requests := s.filter([]string{method})
+		changed := s.changed
+		s.mu.Unlock()
+
+		if len(requests) >= n {
+			return requests, true
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return requests, false
+		}
+	}
+}
+
+// Reset forgets the recorded requests.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+func (s *Server) filter(methods []string) []Request {
+	var requests []Request
+	for _, request := range s.requests {
+		if len(methods) == 0 {
+			requests = append(requests, request)
+			continue
+		}
+		for _, method := range methods {
+			if request.Method == method {
+				requests = append(requests, request)
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// notify wakes up everyone waiting for a change. Must be called with the lock held.
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) newMessageID() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextMessageID
+	s.nextMessageID++
+	return id
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/file/") {
+		s.serveFile(w, r)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if len(parts) != 2 || parts[0] != "bot"+Token {
+		writeError(w, &tgbotapi.Error{Code: http.StatusUnauthorized, Message: "Unauthorized"})
+		return
+	}
+	method := parts[1]
+
+	if err := parseParams(r); err != nil {
+		writeError(w, &tgbotapi.Error{Code: http.StatusBadRequest, Message: err.Error()})
+		return
+	}
+
+	if method == "getUpdates" {
+		writeResult(w, s.getUpdates(r.Form))
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Method: method, Params: r.Form, Time: time.Now()})
+	s.notify()
+	handler, ok := s.handlers[method]
+	s.mu.Unlock()
+
+	if !ok {
+		handler = s.defaultHandler(method)
+	}
+
+	result, err := handler(r.Form)
+	if err != nil {
+		apiErr, ok := err.(*tgbotapi.Error)
+		if !ok {
+			apiErr = &tgbotapi.Error{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		writeError(w, apiErr)
+		return
+	}
+	writeResult(w, result)
+}
+
+// defaultHandler answers like Telegram would on success.
+func (s *Server) defaultHandler(method string) HandlerFunc {
+	switch method {
+	case "getMe":
+		return func(url.Values) (interface{}, error) { return s.Me, nil }
+	case "sendMessage", "sendPhoto", "sendDocument", "sendAnimation", "sendSticker", "forwardMessage":
+		return s.sentMessage
+	case "editMessageText", "editMessageReplyMarkup":
+		return s.editedMessage
+	case "copyMessage":
+		return func(url.Values) (interface{}, error) {
+			return tgbotapi.MessageID{MessageID: s.newMessageID()}, nil
+		}
+	case "getChatMember":
+		return func(params url.Values) (interface{}, error) {
+			userId, _ := strconv.ParseInt(params.Get("user_id"), 10, 64)
+			return tgbotapi.ChatMember{User: &tgbotapi.User{ID: userId}, Status: "member"}, nil
+		}
+	case "getFile":
+		return s.file
+	case "getChatAdministrators", "getMyCommands":
+		return func(url.Values) (interface{}, error) { return []interface{}{}, nil }
+	}
+	return func(url.Values) (interface{}, error) { return true, nil }
+}
+
+func (s *Server) sentMessage(params url.Values) (interface{}, error) {
+	chatId, _ := strconv.ParseInt(params.Get("chat_id"), 10, 64)
+	me := s.Me
+	message := tgbotapi.Message{
+		MessageID: s.newMessageID(),
+		From:      &me,
+		Chat:      &tgbotapi.Chat{ID: chatId},
+		Date:      int(time.Now().Unix()),
+		Text:      params.Get("text"),
+	}
+	if replyTo, err := strconv.Atoi(params.Get("reply_to_message_id")); err == nil {
+		message.ReplyToMessage = &tgbotapi.Message{MessageID: replyTo, Chat: message.Chat}
+	}
+	return message, nil
+}
+
+func (s *Server) editedMessage(params url.Values) (interface{}, error) {
+	if params.Get("inline_message_id") != "" {
+		return true, nil
+	}
+	chatId, _ := strconv.ParseInt(params.Get("chat_id"), 10, 64)
+	messageId, _ := strconv.Atoi(params.Get("message_id"))
+	me := s.Me
+	return tgbotapi.Message{
+		MessageID: messageId,
+		From:      &me,
+		Chat:      &tgbotapi.Chat{ID: chatId},
+		Date:      int(time.Now().Unix()),
+		Text:      params.Get("text"),
+	}, nil
+}
+
+// file returns the file added with AddFile, its path is the file_id.
+func (s *Server) file(params url.Values) (interface{}, error) {
+	fileId := params.Get("file_id")
+	s.mu.Lock()
+	data, ok := s.files[fileId]
+	s.mu.Unlock()
+	if !ok {
+		return nil, &tgbotapi.Error{Code: http.StatusBadRequest, Message: "Bad Request: invalid file_id"}
+	}
+	return tgbotapi.File{FileID: fileId, FileUniqueID: fileId, FileSize: len(data), FilePath: "documents/" + fileId}, nil
+}
+
+// serveFile serves the downloads of the files added with AddFile.
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request) {
+	prefix := "/file/bot" + Token + "/documents/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	s.mu.Lock()
+	data, ok := s.files[strings.TrimPrefix(r.URL.Path, prefix)]
+	s.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Write(data)
+}
+
+// getUpdates long polls for the updates starting at the offset parameter.
+func (s *Server) getUpdates(params url.Values) []tgbotapi.Update {
+	offset, _ := strconv.Atoi(params.Get("offset"))
+	timeout, _ := strconv.Atoi(params.Get("timeout"))
+	wait := time.Duration(timeout) * time.Second
+	if wait > maxPollTimeout {
+		wait = maxPollTimeout
+	}
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+
+	for {
+		s.mu.Lock()
+		updates := []tgbotapi.Update{}
+		for _, update := range s.updates {
+			if update.UpdateID >= offset {
+				updates = append(updates, update)
+			}
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		if len(updates) > 0 {
+			return updates
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return updates
+		case <-s.quit:
+			return updates
+		}
+	}
+}
+
+func parseParams(r *http.Request) error {
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType == "multipart/form-data" {
+		return r.ParseMultipartForm(32 << 20)
+	}
+	return r.ParseForm()
+}
+
+func writeResult(w http.ResponseWriter, result interface{}) {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		writeError(w, &tgbotapi.Error{Code: http.StatusInternalServerError, Message: err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: raw})
+}
+
+func writeError(w http.ResponseWriter, apiErr *tgbotapi.Error) {
+	parameters := apiErr.ResponseParameters
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Code)
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{
+		Ok:          false,
+		ErrorCode:   apiErr.Code,
+		Description: apiErr.Message,
+		Parameters:  &parameters,
+	})
+}
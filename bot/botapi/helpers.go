@@ -0,0 +1,113 @@
+package botapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// GetChatMember returns the membership of a user in a chat.
+func GetChatMember(bot Bot, chatId, userId int64) (tgbotapi.ChatMember, error) {
+	var member tgbotapi.ChatMember
+	resp, err := bot.Request(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatId, UserID: userId},
+	})
+	if err != nil {
+		return member, err
+	}
+	err = json.Unmarshal(resp.Result, &member)
+	return member, err
+}
+
+// GetChatAdministrators returns the administrators of a chat.
+func GetChatAdministrators(bot Bot, chatId int64) ([]tgbotapi.ChatMember, error) {
+	var members []tgbotapi.ChatMember
+	resp, err := bot.Request(tgbotapi.ChatAdministratorsConfig{
+		ChatConfig: tgbotapi.ChatConfig{ChatID: chatId},
+	})
+	if err != nil {
+		return members, err
+	}
+	err = json.Unmarshal(resp.Result, &members)
+	return members, err
+}
+
+// GetChat returns up to date information about a chat, including the
+// default permissions of its members.
+func GetChat(bot Bot, chatId int64) (tgbotapi.Chat, error) {
+	var chat tgbotapi.Chat
+	resp, err := bot.Request(tgbotapi.ChatInfoConfig{
+		ChatConfig: tgbotapi.ChatConfig{ChatID: chatId},
+	})
+	if err != nil {
+		return chat, err
+	}
+	err = json.Unmarshal(resp.Result, &chat)
+	return chat, err
+}
+
+// ErrFileTooBig is returned when downloading a file bigger than allowed.
+var ErrFileTooBig = errors.New("file too big")
+
+// Client of the file downloads, the Bot API client is not used as it has no
+// timeout
+var downloadClient = &http.Client{Timeout: time.Minute}
+
+// DownloadFile downloads a file sent to the bot, up to maxSize bytes. The
+// file endpoint is derived from the Bot API endpoint, so it also works with
+// self hosted Bot API servers.
+func DownloadFile(ctx context.Context, bot Bot, apiEndpoint, token, fileId string, maxSize int64) ([]byte, error) {
+	var file tgbotapi.File
+	resp, err := bot.Request(tgbotapi.FileConfig{FileID: fileId})
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(resp.Result, &file); err != nil {
+		return nil, err
+	}
+	if int64(file.FileSize) > maxSize {
+		return nil, ErrFileTooBig
+	}
+
+	// The file URL has the token in it, it is left out of the errors
+	fileEndpoint := strings.Replace(apiEndpoint, "/bot%s/%s", "/file/bot%s/%s", 1)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(fileEndpoint, token, file.FilePath), nil)
+	if err != nil {
+		return nil, withoutURL(err)
+	}
+	rsp, err := downloadClient.Do(req)
+	if err != nil {
+		return nil, withoutURL(err)
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to download file: %s", rsp.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(rsp.Body, maxSize+1))
+	if err != nil {
+		return nil, withoutURL(err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, ErrFileTooBig
+	}
+	return data, nil
+}
+
+// withoutURL strips the URL from the errors of the HTTP client.
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("download file: %w", urlErr.Err)
+	}
+	return err
+}
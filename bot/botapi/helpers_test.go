@@ -0,0 +1,33 @@
+package botapi_test
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi/botapitest"
+)
+
+func TestDownloadFileHidesToken(t *testing.T) {
+	server := botapitest.NewServer()
+	defer server.Close()
+	server.Handle("getFile", func(url.Values) (interface{}, error) {
+		return tgbotapi.File{FileID: "file", FilePath: "documents/fbans.json", FileSize: 10}, nil
+	})
+	bot, err := server.Bot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing listens on the file endpoint
+	_, err = botapi.DownloadFile(context.Background(), botapi.Wrap(bot), "http://127.0.0.1:1/bot%s/%s", botapitest.Token, "file", 1<<10)
+	if err == nil {
+		t.Fatal("download did not fail")
+	}
+	if strings.Contains(err.Error(), botapitest.Token) {
+		t.Errorf("error has the token: %s", err)
+	}
+}
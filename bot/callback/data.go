@@ -0,0 +1,108 @@
+package callback
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// Telegram refuses callback data longer than 64 bytes
+	maxDataSize = 64
+
+	separator = "|"
+
+	// Bytes of the HMAC kept in the callback data
+	signatureSize = 6
+)
+
+var (
+	ErrTooLong          = errors.New("callback data is longer than 64 bytes")
+	ErrInvalidSignature = errors.New("callback data signature is not valid")
+	ErrMalformed        = errors.New("callback data is malformed")
+)
+
+var (
+	secretMu sync.RWMutex
+	secret   []byte
+)
+
+// SetSecret sets the key used to sign the callback data. Clients can send
+// any callback data, the signature ensures the data was built by the bot.
+func SetSecret(key []byte) {
+	secretMu.Lock()
+	defer secretMu.Unlock()
+	secret = key
+}
+
+// Data is a decoded callback data: the namespace of the handler and its arguments.
+type Data struct {
+	Namespace string
+	Args      []string
+}
+
+// Arg returns the i-th argument or an empty string if there is none.
+func (d Data) Arg(i int) string {
+	if i < len(d.Args) {
+		return d.Args[i]
+	}
+	return ""
+}
+
+// Encode builds the signed callback data "namespace|arg...|signature".
+func Encode(namespace string, args ...string) (string, error) {
+	fields := append([]string{namespace}, args...)
+	for _, field := range fields {
+		if strings.Contains(field, separator) {
+			return "", fmt.Errorf("callback field %q contains %q", field, separator)
+		}
+	}
+
+	payload := strings.Join(fields, separator)
+	data := payload + separator + sign(payload)
+	if len(data) > maxDataSize {
+		return "", ErrTooLong
+	}
+	return data, nil
+}
+
+// Decode checks the signature of data and splits it.
+func Decode(data string) (Data, error) {
+	i := strings.LastIndex(data, separator)
+	if i <= 0 {
+		return Data{}, ErrMalformed
+	}
+
+	payload, signature := data[:i], data[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(sign(payload))) {
+		return Data{}, ErrInvalidSignature
+	}
+
+	fields := strings.Split(payload, separator)
+	return Data{Namespace: fields[0], Args: fields[1:]}, nil
+}
+
+// Button returns an inline keyboard button carrying the signed callback data.
+// It panics if the data is too long, as that is always a programming error.
+func Button(text, namespace string, args ...string) tgbotapi.InlineKeyboardButton {
+	data, err := Encode(namespace, args...)
+	if err != nil {
+		panic(err)
+	}
+	return tgbotapi.NewInlineKeyboardButtonData(text, data)
+}
+
+func sign(payload string) string {
+	secretMu.RLock()
+	mac := hmac.New(sha256.New, secret)
+	secretMu.RUnlock()
+
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:signatureSize])
+}
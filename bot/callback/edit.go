@@ -0,0 +1,54 @@
+package callback
+
+import (
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+)
+
+// EditText replaces the text and keyboard of the message the callback query
+// button belongs to. Editing a message with the same content is not an error.
+func EditText(hebeBot botapi.Bot, query *tgbotapi.CallbackQuery, text, parseMode string, markup *tgbotapi.InlineKeyboardMarkup) error {
+	edit := tgbotapi.EditMessageTextConfig{
+		BaseEdit: tgbotapi.BaseEdit{
+			InlineMessageID: query.InlineMessageID,
+			ReplyMarkup:     markup,
+		},
+		Text:      text,
+		ParseMode: parseMode,
+	}
+	if query.Message != nil {
+		edit.ChatID = query.Message.Chat.ID
+		edit.MessageID = query.Message.MessageID
+	}
+
+	_, err := hebeBot.Request(edit)
+	return ignoreNotModified(err)
+}
+
+// EditMarkup replaces the keyboard of the message the callback query button
+// belongs to. A nil markup removes the keyboard.
+func EditMarkup(hebeBot botapi.Bot, query *tgbotapi.CallbackQuery, markup *tgbotapi.InlineKeyboardMarkup) error {
+	edit := tgbotapi.EditMessageReplyMarkupConfig{
+		BaseEdit: tgbotapi.BaseEdit{
+			InlineMessageID: query.InlineMessageID,
+			ReplyMarkup:     markup,
+		},
+	}
+	if query.Message != nil {
+		edit.ChatID = query.Message.Chat.ID
+		edit.MessageID = query.Message.MessageID
+	}
+
+	_, err := hebeBot.Request(edit)
+	return ignoreNotModified(err)
+}
+
+// Telegram refuses edits that don't change the message
+func ignoreNotModified(err error) error {
+	if err != nil && strings.Contains(err.Error(), "message is not modified") {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,273 @@
+// Package captcha verifies the new members are not spam accounts before
+// welcoming them. New members are restricted until they solve the captcha,
+// the ones that do not solve it in time are kicked.
+package captcha
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/actions"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/callback"
+	"github.com/hestingames/hg-hebe-bot/bot/moderation"
+	"github.com/hestingames/hg-hebe-bot/bot/router"
+	"github.com/hestingames/hg-hebe-bot/config"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+	"github.com/hestingames/hg-hebe-bot/internal/scheduler"
+	"github.com/hestingames/hg-hebe-bot/internal/store"
+	"go.uber.org/zap"
+)
+
+const (
+	callbackNamespace = "captcha"
+	timeoutJobKind    = "captcha.timeout"
+	pendingKeyPrefix  = "captcha."
+
+	// Choices offered by the arithmetic captcha
+	arithmeticChoices = 4
+)
+
+const (
+	buttonMessage     = "👋 Hola %s, pulse el botón para demostrar que no es un robot. Tiene %s para hacerlo."
+	arithmeticMessage = "👋 Hola %s, para demostrar que no es un robot responda en menos de %s: ¿Cuánto es %d + %d?"
+	buttonText        = "✅ No soy un robot"
+	notYoursMessage   = "🙅🏻‍♀️ Esta verificación no es para usted"
+	expiredMessage    = "⌛️ Esta verificación ha caducado"
+	passedMessage     = "✅ Verificación completada"
+	failedMessage     = "❌ Respuesta incorrecta"
+	failedReason      = "Verificación no superada"
+)
+
+// pending is a captcha waiting to be solved
+type pending struct {
+	ChatID    int64
+	User      tgbotapi.User
+	MessageID int
+	Answer    int
+	Deadline  time.Time
+}
+
+var (
+	logger *logs.Logger
+	state  *store.Store
+	jobs   *scheduler.Scheduler
+
+	// Serializes the resolution of the captchas, so a captcha solved right
+	// when it times out is only resolved once
+	pendingMu sync.Mutex
+
+	random   = rand.New(rand.NewSource(time.Now().UnixNano()))
+	randomMu sync.Mutex
+)
+
+// Initialize sets where the pending captchas are persisted and handles
+// their timeouts with jobs, so they also expire across restarts.
+func Initialize(log *logs.Logger, hebeBot botapi.Bot, st *store.Store, sched *scheduler.Scheduler) {
+	logger = log
+	state = st
+	jobs = sched
+
+	jobs.Handle(timeoutJobKind, func(job scheduler.Job) error {
+		var p pending
+		if err := job.Decode(&p); err != nil {
+			logger.Error("Invalid captcha timeout job", zap.String("job", job.ID), zap.Error(err))
+			return nil
+		}
+		if p, ok := take(p.ChatID, p.User.ID); ok {
+			logger.Info("Captcha timed out", zap.Int64("chat", p.ChatID), zap.Int64("user", p.User.ID))
+			fail(hebeBot, p)
+		}
+		return nil
+	})
+}
+
+// Enabled reports whether the new members of the chat must solve a captcha.
+func Enabled(chatId int64) bool {
+	mode := config.AppConfig.Captcha(chatId)
+	return mode == config.CaptchaButton || mode == config.CaptchaArithmetic
+}
+
+// Callback returns the handler of the captcha buttons.
+func Callback() router.Callback {
+	return router.Callback{
+		Namespace: callbackNamespace,
+		Scope:     router.ScopeGroup,
+		Handler:   handleAnswer,
+	}
+}
+
+// Challenge restricts the new member and posts the captcha.
+func Challenge(ctx context.Context, hebeBot botapi.Bot, chatId int64, user tgbotapi.User) error {
+	if _, err := hebeBot.Request(tgbotapi.RestrictChatMemberConfig{
+		ChatMemberConfig: tgbotapi.ChatMemberConfig{ChatID: chatId, UserID: user.ID},
+		Permissions:      &tgbotapi.ChatPermissions{},
+	}); err != nil {
+		return err
+	}
+
+	timeout := config.AppConfig.CaptchaTimeout.Get()
+	p := pending{
+		ChatID:   chatId,
+		User:     user,
+		Deadline: time.Now().Add(timeout),
+	}
+	userId := strconv.FormatInt(user.ID, 10)
+
+	msg := tgbotapi.NewMessage(chatId, "")
+	if config.AppConfig.Captcha(chatId) == config.CaptchaArithmetic {
+		randomMu.Lock()
+		a, b := random.Intn(9)+1, random.Intn(9)+1
+		randomMu.Unlock()
+		p.Answer = a + b
+		msg.Text = fmt.Sprintf(arithmeticMessage, name(user), moderation.FormatDuration(timeout), a, b)
+
+		var row []tgbotapi.InlineKeyboardButton
+		for _, choice := range choices(p.Answer) {
+			row = append(row, callback.Button(strconv.Itoa(choice), callbackNamespace, userId, strconv.Itoa(choice)))
+		}
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(row)
+	} else {
+		msg.Text = fmt.Sprintf(buttonMessage, name(user), moderation.FormatDuration(timeout))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			callback.Button(buttonText, callbackNamespace, userId, "0"),
+		))
+	}
+
+	sent, err := hebeBot.Send(msg)
+	if err != nil {
+		return err
+	}
+	p.MessageID = sent.MessageID
+
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	if err := state.Put(pendingKey(chatId, user.ID), p); err != nil {
+		return err
+	}
+	job, err := scheduler.NewJob(pendingKey(chatId, user.ID), timeoutJobKind, p.Deadline, p)
+	if err != nil {
+		return err
+	}
+	return jobs.Schedule(job)
+}
+
+// HandleChatMember drops the pending captcha of the members leaving the chat.
+func HandleChatMember(hebeBot botapi.Bot, updated *tgbotapi.ChatMemberUpdated) {
+	if updated == nil || updated.NewChatMember.User == nil {
+		return
+	}
+	if !updated.NewChatMember.HasLeft() && !updated.NewChatMember.WasKicked() {
+		return
+	}
+	if p, ok := take(updated.Chat.ID, updated.NewChatMember.User.ID); ok {
+		hebeBot.Enqueue(tgbotapi.NewDeleteMessage(p.ChatID, p.MessageID))
+	}
+}
+
+func handleAnswer(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, query *tgbotapi.CallbackQuery, data callback.Data) router.Answer {
+	userId, err := strconv.ParseInt(data.Arg(0), 10, 64)
+	if err != nil {
+		return router.Answer{}
+	}
+	if query.From.ID != userId {
+		return router.Answer{Text: notYoursMessage, Alert: true}
+	}
+
+	p, ok := take(query.Message.Chat.ID, userId)
+	if !ok {
+		return router.Answer{Text: expiredMessage}
+	}
+
+	if data.Arg(1) != strconv.Itoa(p.Answer) {
+		logger.Info("Captcha failed", zap.Int64("chat", p.ChatID), zap.Int64("user", userId))
+		fail(hebeBot, p)
+		return router.Answer{Text: failedMessage, Alert: true}
+	}
+
+	logger.Info("Captcha passed", zap.Int64("chat", p.ChatID), zap.Int64("user", userId))
+	if _, err := hebeBot.Request(tgbotapi.RestrictChatMemberConfig{
+		ChatMemberConfig: tgbotapi.ChatMemberConfig{ChatID: p.ChatID, UserID: userId},
+		Permissions:      moderation.DefaultPermissions(hebeBot, p.ChatID),
+	}); err != nil {
+		logger.Error("Unable to lift the captcha restriction", zap.Int64("chat", p.ChatID), zap.Int64("user", userId), zap.Error(err))
+	}
+	hebeBot.Enqueue(tgbotapi.NewDeleteMessage(p.ChatID, p.MessageID))
+
+	if chat, _ := config.AppConfig.Chat(p.ChatID); chat.Welcome {
+		actions.SayHello(ctx, logger, hebeBot, p.ChatID, p.User)
+	}
+	return router.Answer{Text: passedMessage}
+}
+
+// take removes and returns the pending captcha of the user in the chat.
+func take(chatId, userId int64) (pending, bool) {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+
+	var p pending
+	key := pendingKey(chatId, userId)
+	ok, err := state.Get(key, &p)
+	if err != nil {
+		logger.Error("Unable to load pending captcha", zap.String("key", key), zap.Error(err))
+	}
+	if !ok || err != nil {
+		return p, false
+	}
+	if err := state.Delete(key); err != nil {
+		logger.Error("Unable to delete pending captcha", zap.String("key", key), zap.Error(err))
+	}
+	if err := jobs.Cancel(key); err != nil {
+		logger.Error("Unable to cancel captcha timeout", zap.String("key", key), zap.Error(err))
+	}
+	return p, true
+}
+
+// fail kicks the user that did not solve the captcha and removes it.
+func fail(hebeBot botapi.Bot, p pending) {
+	moderation.Apply(logger, hebeBot, moderation.Action{
+		Kind:   moderation.Kick,
+		ChatID: p.ChatID,
+		Target: p.User,
+		Admin:  hebeBot.Self(),
+		Reason: failedReason,
+	})
+	hebeBot.Enqueue(tgbotapi.NewDeleteMessage(p.ChatID, p.MessageID))
+}
+
+// choices returns the shuffled choices of the arithmetic captcha, the
+// answer included.
+func choices(answer int) []int {
+	randomMu.Lock()
+	defer randomMu.Unlock()
+
+	choices := []int{answer}
+	for len(choices) < arithmeticChoices {
+		choice := random.Intn(17) + 2
+		duplicated := false
+		for _, c := range choices {
+			duplicated = duplicated || c == choice
+		}
+		if !duplicated {
+			choices = append(choices, choice)
+		}
+	}
+	random.Shuffle(len(choices), func(i, j int) { choices[i], choices[j] = choices[j], choices[i] })
+	return choices
+}
+
+func name(user tgbotapi.User) string {
+	if user.UserName != "" {
+		return "@" + user.UserName
+	}
+	return user.FirstName
+}
+
+func pendingKey(chatId, userId int64) string {
+	return fmt.Sprintf("%s%d.%d", pendingKeyPrefix, chatId, userId)
+}
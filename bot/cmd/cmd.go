@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"github.com/hestingames/hg-hebe-bot/bot/router"
+)
+
+// commands, callbacks and inlines hold every handler declared in this package. Each
+// command file adds its own declarations from init.
+var (
+	commands  []router.Command
+	callbacks []router.Callback
+	inlines   []router.Inline
+)
+
+func register(command router.Command) {
+	commands = append(commands, command)
+}
+
+func registerCallback(cb router.Callback) {
+	callbacks = append(callbacks, cb)
+}
+
+func registerInline(inline router.Inline) {
+	inlines = append(inlines, inline)
+}
+
+// Register adds every command, callback and inline query handler of this
+// package to r.
+func Register(r *router.Router) {
+	r.Register(commands...)
+	r.Register(helpCommand(r))
+	r.RegisterCallback(callbacks...)
+	r.RegisterInline(inlines...)
+}
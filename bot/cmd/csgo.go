@@ -1,74 +1,221 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	csgoapi "github.com/hestingames/hg-hebe-bot/api"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/callback"
+	"github.com/hestingames/hg-hebe-bot/bot/moderation"
+	"github.com/hestingames/hg-hebe-bot/bot/router"
 	"github.com/hestingames/hg-hebe-bot/internal/logs"
+	"github.com/hestingames/hg-hebe-bot/internal/store"
 )
 
+// Last status message sent on each chat. Updates of different chats are
+// handled concurrently, so every access must hold statusMessagesMu
 var (
-	statusMessages map[int64]int
+	statusMessages   = make(map[int64]int)
+	statusMessagesMu sync.Mutex
 )
 
+// Client of the CSGO api the stats are retrieved from
+var csgo *csgoapi.Client
+
+// Store key of the status messages sent on each chat
+const statusMessagesKey = "cmd.csgo.statusMessages"
+
 const StatsRetriveErrorMessage = "El servicio se encuentra : *ONLINE*\n" +
 	"Ha ocurrido un error al obtener las estadísticas 😅\n"
 
-func HandleStatus(logger *logs.Logger, hebeBot tgbotapi.BotAPI, update tgbotapi.Update) {
-	// FIXME
-	if len(statusMessages) == 0 {
-		statusMessages = make(map[int64]int)
-	}
+const StatsDegradedMessage = "El servicio se encuentra : *DEGRADADO*\n" +
+	"Las estadísticas no están disponibles temporalmente ⚠️\n"
+
+const (
+	queuesUnavailableMessage = "\n😅 No se han podido obtener las colas de Matchmaking\n"
+	inconsistentStatsMessage = "\n⚠️ Los servidores y las colas de Matchmaking no coinciden, las cifras pueden no ser exactas\n"
+)
+
+const (
+	statusRefreshCooldown     = 10 * time.Second
+	statusRefreshWaitMessage  = "⏳ Espere unos segundos antes de volver a actualizar"
+	statusRefreshedMessage    = "✅ Estadísticas actualizadas"
+	statusRefreshErrorMessage = "😅 Ha ocurrido un error al actualizar las estadísticas"
+)
+
+// Last refresh of each status message
+var (
+	statusRefreshes   = make(map[string]time.Time)
+	statusRefreshesMu sync.Mutex
+)
+
+func init() {
+	register(router.Command{
+		Name:        "csgo",
+		Aliases:     []string{"status"},
+		Description: "Estado del servicio de Counter-Strike: Global Offensive",
+		Localized:   map[string]string{"en": "Counter-Strike: Global Offensive service status"},
+		Scope:       router.ScopeAll,
+		Handler:     HandleStatus,
+	})
+	registerCallback(router.Callback{
+		Namespace: "csgo",
+		Scope:     router.ScopeAll,
+		Handler:   HandleStatusRefresh,
+	})
+}
+
+// Initialize sets the client of the CSGO api used by the commands.
+func Initialize(client *csgoapi.Client) {
+	csgo = client
+}
 
+func HandleStatus(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
 	chatId := update.Message.Chat.ID
 
 	msg := tgbotapi.NewMessage(chatId, "")
 	msg.ReplyToMessageID = update.Message.MessageID
 	msg.ParseMode = "markdown" // html, markdown
+	msg.ReplyMarkup = statusKeyboard()
+
+	hebeBot.Enqueue(tgbotapi.NewChatAction(chatId, tgbotapi.ChatTyping))
+
+	msg.Text = statusText(fetchStatus(ctx, logger))
+
+	// Remove older status message
+	statusMessagesMu.Lock()
+	messageId, ok := statusMessages[chatId]
+	statusMessagesMu.Unlock()
+	if ok {
+		hebeBot.Enqueue(tgbotapi.NewDeleteMessage(chatId, messageId))
+	}
+
+	// Send current status message
+	if rsp, err := hebeBot.Send(msg); err == nil {
+		statusMessagesMu.Lock()
+		statusMessages[chatId] = rsp.MessageID
+		statusMessagesMu.Unlock()
+	}
+}
+
+// HandleStatusRefresh updates the status message the refresh button belongs to.
+func HandleStatusRefresh(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, query *tgbotapi.CallbackQuery, data callback.Data) router.Answer {
+	key := query.InlineMessageID
+	if query.Message != nil {
+		key = fmt.Sprintf("%d/%d", query.Message.Chat.ID, query.Message.MessageID)
+	}
 
-	hebeBot.Send(tgbotapi.NewChatAction(chatId, tgbotapi.ChatTyping))
+	// Each status message can be refreshed once per statusRefreshCooldown
+	statusRefreshesMu.Lock()
+	now := time.Now()
+	if last, ok := statusRefreshes[key]; ok && now.Sub(last) < statusRefreshCooldown {
+		statusRefreshesMu.Unlock()
+		return router.Answer{Text: statusRefreshWaitMessage}
+	}
+	for k, last := range statusRefreshes {
+		if now.Sub(last) >= statusRefreshCooldown {
+			delete(statusRefreshes, k)
+		}
+	}
+	statusRefreshes[key] = now
+	statusRefreshesMu.Unlock()
+
+	keyboard := statusKeyboard()
+	if err := callback.EditText(hebeBot, query, statusText(fetchStatus(ctx, logger)), "markdown", &keyboard); err != nil {
+		logger.Sugar().Errorf("Unable to refresh status :%s", err)
+		return router.Answer{Text: statusRefreshErrorMessage}
+	}
+	return router.Answer{Text: statusRefreshedMessage}
+}
+
+func statusKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(callback.Button("🔄 Actualizar", "csgo", "refresh")),
+	)
+}
+
+// fetchStatus gets the snapshot of the CSGO api stats, logging the
+// inconsistencies found in it.
+func fetchStatus(ctx context.Context, logger *logs.Logger) csgoapi.StatusSnapshot {
+	// Failing to get any part is carried by the snapshot
+	snapshot, _ := csgo.GetStatusSnapshot(ctx)
+	for _, warning := range snapshot.Warnings {
+		logger.Sugar().Debugf("Inconsistent CSGO status: %s", warning)
+	}
+	return snapshot
+}
 
-	msg.Text = "🕹 [HestinGames](http://hestingames.nat.cu)\n" +
+// statusText builds the status message from a snapshot of the CSGO api stats.
+func statusText(snapshot csgoapi.StatusSnapshot) string {
+	text := "🕹 [HestinGames](http://hestingames.nat.cu)\n" +
 		"🎮 *Counter-Strike: Global Offensive*\n" +
 		"🛒 [Mercado](https://csgo.hestingames.nat.cu)\n\n"
 
-	if playingNow, err := csgoapi.GetPlayingNow(); err != nil {
-		msg.Text += StatsRetriveErrorMessage
+	if snapshot.ServersErr != nil && snapshot.QueuesErr != nil {
+		text += statsErrorText(snapshot.ServersErr)
+		return text + fmt.Sprintf("\n🕒 Actualizado: %s", time.Now().Format("15:04:05"))
+	}
+
+	// Whatever was retrieved is shown, noting what is missing or does not add up
+	text += fmt.Sprintf("📊 Estadísticas del Servicio 📊\n"+
+		"🔫 Playing Now: %d\n\n", snapshot.PlayingNow)
+
+	if snapshot.QueuesErr != nil {
+		text += queuesUnavailableMessage
 	} else {
-		msg.Text += fmt.Sprintf("📊 Estadísticas del Servicio 📊\n"+
-			"🔫 Playing Now: %d\n\n", playingNow)
-
-		if queueStatus, err := csgoapi.GetMatchakingQueueStatus(); err != nil {
-			msg.Text += StatsRetriveErrorMessage
-		} else {
-			serverStatus := csgoapi.ParseServerStatus(queueStatus)
-
-			// HACK : Sometimes the API fucks up and retrieves invalid queue info
-			if playingNow != int(serverStatus.PlayingNow) {
-				msg.Text += fmt.Sprintf("\n"+
-					"📯 Matchmaking Casual 📯\n"+
-					"Sigma: %d\n"+
-					"Delta: %d\n"+
-					"Dust II: %d\n"+
-					"Hostages : %d\n",
-					serverStatus.SigmaPlaying,
-					serverStatus.DeltaPlaying,
-					serverStatus.DustIIPlaying,
-					serverStatus.HostagesPlaying)
-			} else {
-				msg.Text += StatsRetriveErrorMessage
-			}
-		}
+		text += fmt.Sprintf("\n"+
+			"📯 Matchmaking Casual 📯\n"+
+			"Sigma: %d\n"+
+			"Delta: %d\n"+
+			"Dust II: %d\n"+
+			"Hostages : %d\n",
+			snapshot.Status.SigmaPlaying,
+			snapshot.Status.DeltaPlaying,
+			snapshot.Status.DustIIPlaying,
+			snapshot.Status.HostagesPlaying)
 	}
 
-	// Remove older status message
-	if messageId, ok := statusMessages[chatId]; ok {
-		hebeBot.Send(tgbotapi.NewDeleteMessage(chatId, messageId))
+	if !snapshot.Consistent() {
+		text += inconsistentStatsMessage
 	}
 
-	// Send current status message
-	if rsp, err := hebeBot.Send(msg); err == nil {
-		statusMessages[chatId] = rsp.MessageID
+	// The data may come from the cache
+	text += fmt.Sprintf("\n🕒 Actualizado: %s", snapshot.Fetched.Format("15:04:05"))
+	if age := time.Since(snapshot.Fetched); age >= time.Second {
+		text += fmt.Sprintf(" (hace %s)", moderation.FormatDuration(age))
 	}
+	return text
+}
+
+// statsErrorText tells the users whether the stats could not be retrieved
+// because the service is degraded or because of any other error.
+func statsErrorText(err error) string {
+	if csgoapi.IsDegraded(err) {
+		return StatsDegradedMessage
+	}
+	return StatsRetriveErrorMessage
+}
+
+// LoadState restores the status messages sent before the last shutdown, so
+// they are still replaced by the next status message.
+func LoadState(st *store.Store) error {
+	messages := make(map[int64]int)
+	if _, err := st.Get(statusMessagesKey, &messages); err != nil {
+		return err
+	}
+	statusMessagesMu.Lock()
+	statusMessages = messages
+	statusMessagesMu.Unlock()
+	return nil
+}
+
+// SaveState persists the status messages sent on each chat.
+func SaveState(st *store.Store) error {
+	statusMessagesMu.Lock()
+	defer statusMessagesMu.Unlock()
+	return st.Put(statusMessagesKey, statusMessages)
 }
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	csgoapi "github.com/hestingames/hg-hebe-bot/api"
+)
+
+func TestStatusText(t *testing.T) {
+	errDown := errors.New("api down")
+	tests := []struct {
+		name     string
+		snapshot csgoapi.StatusSnapshot
+		want     []string
+		unwanted []string
+	}{
+		{
+			name:     "unavailable",
+			snapshot: csgoapi.StatusSnapshot{ServersErr: errDown, QueuesErr: errDown},
+			want:     []string{"error al obtener las estadísticas"},
+			unwanted: []string{"Playing Now"},
+		},
+		{
+			name: "complete",
+			snapshot: csgoapi.StatusSnapshot{
+				PlayingNow: 4,
+				Status:     csgoapi.CsgoServerStatus{DustIIPlaying: 3, HostagesPlaying: 1},
+				Fetched:    time.Now(),
+			},
+			want:     []string{"Playing Now: 4", "Dust II: 3", "Hostages : 1"},
+			unwanted: []string{"no coinciden", "No se han podido obtener las colas"},
+		},
+		{
+			name:     "without queues",
+			snapshot: csgoapi.StatusSnapshot{PlayingNow: 4, QueuesErr: errDown, Fetched: time.Now()},
+			want:     []string{"Playing Now: 4", "No se han podido obtener las colas"},
+			unwanted: []string{"Dust II"},
+		},
+		{
+			name: "inconsistent",
+			snapshot: csgoapi.StatusSnapshot{
+				PlayingNow: 4,
+				Warnings:   []csgoapi.Warning{{Kind: csgoapi.PlayersMismatch, Servers: 4, Queues: 3}},
+				Fetched:    time.Now(),
+			},
+			want: []string{"no coinciden"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text := statusText(test.snapshot)
+			for _, want := range test.want {
+				if !strings.Contains(text, want) {
+					t.Errorf("status missing %q:\n%s", want, text)
+				}
+			}
+			for _, unwanted := range test.unwanted {
+				if strings.Contains(text, unwanted) {
+					t.Errorf("status has %q:\n%s", unwanted, text)
+				}
+			}
+		})
+	}
+}
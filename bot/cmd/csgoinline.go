@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	csgoapi "github.com/hestingames/hg-hebe-bot/api"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/router"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+)
+
+const (
+	inlineStatusTitle  = "🎮 Estado del servicio"
+	inlineQueuesTitle  = "📯 Colas de Matchmaking"
+	inlineServersTitle = "🖥 Servidores"
+
+	// Servers listed in the servers card, Telegram messages are limited to 4096 characters
+	inlineMaxServers = 30
+)
+
+// Matchmaking modes in the order they are shown
+var gameTypes = []csgoapi.GameType{csgoapi.Sigma, csgoapi.Delta, csgoapi.DustII, csgoapi.Hostages}
+
+func init() {
+	registerInline(router.Inline{
+		Keyword: "csgo",
+		Aliases: []string{"", "status"},
+		Handler: HandleStatusInline,
+	})
+}
+
+// HandleStatusInline shares the CS:GO service status as a card in any chat
+// (@hebebot csgo).
+func HandleStatusInline(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, query *tgbotapi.InlineQuery, args string) []interface{} {
+	snapshot := fetchStatus(ctx, logger)
+
+	status := tgbotapi.NewInlineQueryResultArticleMarkdown("csgo-status", inlineStatusTitle, statusText(snapshot))
+	status.Description = "Jugadores y estadísticas del servicio"
+
+	results := []interface{}{status}
+
+	// Each card is shown when its part of the snapshot was retrieved
+	if snapshot.QueuesErr != nil {
+		logger.Sugar().Errorf("Unable to get matchmaking queues :%s", snapshot.QueuesErr)
+	} else {
+		article := tgbotapi.NewInlineQueryResultArticleMarkdown("csgo-queues", inlineQueuesTitle, queuesText(snapshot.Queues))
+		article.Description = fmt.Sprintf("%d jugando, %d buscando partida", snapshot.Status.PlayingNow, snapshot.Status.SearchingNow)
+		results = append(results, article)
+	}
+
+	if snapshot.ServersErr != nil {
+		logger.Sugar().Errorf("Unable to get servers :%s", snapshot.ServersErr)
+	} else {
+		article := tgbotapi.NewInlineQueryResultArticleMarkdown("csgo-servers", inlineServersTitle, serversText(snapshot.Servers))
+		article.Description = fmt.Sprintf("%d servidores en línea", len(snapshot.Servers))
+		results = append(results, article)
+	}
+
+	return results
+}
+
+func queuesText(queues []csgoapi.MatmakingQueueStatus) string {
+	serverStatus := csgoapi.ParseServerStatus(queues)
+
+	var text strings.Builder
+	text.WriteString("🎮 *Counter-Strike: Global Offensive*\n")
+	text.WriteString("📯 Colas de Matchmaking 📯\n\n")
+	for _, gameType := range gameTypes {
+		playing, searching := 0, 0
+		for _, queue := range queues {
+			if queue.GameType == int(gameType) {
+				playing += queue.CurrentPlaying
+				searching += queue.CurrentSearching
+			}
+		}
+		text.WriteString(fmt.Sprintf("*%s*: %d jugando, %d buscando\n", gameType, playing, searching))
+	}
+	text.WriteString(fmt.Sprintf("\n🔫 Total: %d jugando, %d buscando\n", serverStatus.PlayingNow, serverStatus.SearchingNow))
+
+	return text.String()
+}
+
+func serversText(servers []csgoapi.CsgoServer) string {
+	// Busiest servers first
+	sorted := make([]csgoapi.CsgoServer, len(servers))
+	copy(sorted, servers)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].PlayersId) > len(sorted[j].PlayersId)
+	})
+
+	var text strings.Builder
+	text.WriteString("🎮 *Counter-Strike: Global Offensive*\n")
+	text.WriteString(fmt.Sprintf("🖥 Servidores en línea: %d\n\n", len(sorted)))
+	for i, server := range sorted {
+		if i == inlineMaxServers {
+			text.WriteString(fmt.Sprintf("… y %d más\n", len(sorted)-inlineMaxServers))
+			break
+		}
+		text.WriteString(fmt.Sprintf("#%d %s (%s): %d jugadores\n",
+			server.ServerId,
+			tgbotapi.EscapeText(tgbotapi.ModeMarkdown, server.MapName),
+			csgoapi.GameType(server.GameType),
+			len(server.PlayersId)))
+	}
+
+	return text.String()
+}
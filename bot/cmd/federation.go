@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/federation"
+	"github.com/hestingames/hg-hebe-bot/bot/moderation"
+	"github.com/hestingames/hg-hebe-bot/bot/router"
+	"github.com/hestingames/hg-hebe-bot/config"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+)
+
+const (
+	fbanUsage           = "/fban <@usuario|ID> [motivo]"
+	unfbanUsage         = "/unfban <@usuario|ID>"
+	fbanMessage         = "🌐 %s ha sido baneado de la federación (%d grupos)\n📝 Motivo: %s\n👮🏻 Administrador: %s"
+	unfbanMessage       = "🌐 %s ha sido eliminado de la lista de baneos de la federación"
+	notFbannedMessage   = "🤷🏻‍♀️ %s no está en la lista de baneos de la federación"
+	fbanErrorMessage    = "😅 Ha ocurrido un error al actualizar la lista de baneos de la federación"
+	fbanImportUsage     = "🤷🏻‍♀️ Responda con /fbanimport a un documento JSON exportado con /fbanexport"
+	fbanImportedMessage = "🌐 Se han importado %d baneos nuevos a la federación"
+	fbanImportError     = "😅 No se pudo importar la lista de baneos: %s"
+	fbanDownloadError   = "😅 No se pudo descargar el documento"
+	fbanTooBigMessage   = "🤷🏻‍♀️ El documento es demasiado grande"
+	fbanExportFileName  = "hestingames-fbans.json"
+	fbanImportMaxSize   = 5 << 20
+)
+
+func init() {
+	register(router.Command{
+		Name:        "fban",
+		Description: "Banea a un usuario de todos los grupos de la federación",
+		Localized:   map[string]string{"en": "Bans a user from every group of the federation"},
+		Scope:       router.ScopeGroup | router.ScopeAdmin,
+		Handler:     HandleFban,
+	})
+	register(router.Command{
+		Name:        "unfban",
+		Description: "Elimina a un usuario de la lista de baneos de la federación",
+		Localized:   map[string]string{"en": "Removes a user from the federation ban list"},
+		Scope:       router.ScopeGroup | router.ScopeAdmin,
+		Handler:     HandleUnfban,
+	})
+	register(router.Command{
+		Name:        "fbanexport",
+		Description: "Exporta la lista de baneos de la federación",
+		Localized:   map[string]string{"en": "Exports the federation ban list"},
+		Scope:       router.ScopePrivate | router.ScopeOwner,
+		Handler:     HandleFbanExport,
+	})
+	register(router.Command{
+		Name:        "fbanimport",
+		Description: "Importa una lista de baneos a la federación",
+		Localized:   map[string]string{"en": "Imports a ban list into the federation"},
+		Scope:       router.ScopePrivate | router.ScopeOwner,
+		Handler:     HandleFbanImport,
+	})
+}
+
+// HandleFban bans the replied user or the user given as argument from every
+// chat of the federation.
+func HandleFban(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
+	message := update.Message
+	reply := replier(logger, hebeBot, message)
+
+	if ok, err := canRestrict(hebeBot, message.Chat.ID, message.From); err != nil || !ok {
+		reply(cannotRestrictMessage)
+		return
+	}
+
+	target, args, err := commandTarget(hebeBot, message)
+	if err == nil {
+		err = checkTarget(hebeBot, message.Chat.ID, target)
+	}
+	if err != nil {
+		if text, ok := userMessage(err); ok {
+			reply(text + "\nUso: " + fbanUsage)
+		} else {
+			reply(fbanErrorMessage)
+		}
+		return
+	}
+
+	ban := federation.Ban{
+		UserID:    target.ID,
+		UserName:  moderation.UserName(target),
+		Reason:    strings.Join(args, " "),
+		AdminID:   message.From.ID,
+		AdminName: moderation.UserName(*message.From),
+		Time:      time.Now(),
+	}
+	chats, err := federation.Add(logger, hebeBot, ban, target, *message.From)
+	if err != nil {
+		logger.Sugar().Errorf("Unable to add federation ban :%s", err)
+		reply(fbanErrorMessage)
+		return
+	}
+
+	reason := ban.Reason
+	if reason == "" {
+		reason = noReasonMessage
+	}
+	reply(fmt.Sprintf(fbanMessage, ban.UserName, chats, reason, ban.AdminName))
+}
+
+// HandleUnfban removes the replied user or the user given as argument from
+// the federation ban list.
+func HandleUnfban(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
+	message := update.Message
+	reply := replier(logger, hebeBot, message)
+
+	if ok, err := canRestrict(hebeBot, message.Chat.ID, message.From); err != nil || !ok {
+		reply(cannotRestrictMessage)
+		return
+	}
+
+	target, _, err := commandTarget(hebeBot, message)
+	if err != nil {
+		if text, ok := userMessage(err); ok {
+			reply(text + "\nUso: " + unfbanUsage)
+		} else {
+			reply(fbanErrorMessage)
+		}
+		return
+	}
+
+	found, err := federation.Remove(logger, hebeBot, target, *message.From)
+	switch {
+	case err != nil:
+		logger.Sugar().Errorf("Unable to remove federation ban :%s", err)
+		reply(fbanErrorMessage)
+	case !found:
+		reply(fmt.Sprintf(notFbannedMessage, moderation.UserName(target)))
+	default:
+		reply(fmt.Sprintf(unfbanMessage, moderation.UserName(target)))
+	}
+}
+
+// HandleFbanExport sends the federation ban list as a JSON document.
+func HandleFbanExport(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
+	data, err := federation.Export()
+	if err != nil {
+		logger.Sugar().Errorf("Unable to export federation bans :%s", err)
+		replier(logger, hebeBot, update.Message)(fbanErrorMessage)
+		return
+	}
+
+	doc := tgbotapi.NewDocument(update.Message.Chat.ID, tgbotapi.FileBytes{Name: fbanExportFileName, Bytes: data})
+	doc.ReplyToMessageID = update.Message.MessageID
+	if _, err := hebeBot.Send(doc); err != nil {
+		logger.Sugar().Errorf("Unable to send federation bans :%s", err)
+	}
+}
+
+// HandleFbanImport imports the bans of the replied JSON document.
+func HandleFbanImport(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
+	reply := replier(logger, hebeBot, update.Message)
+
+	replied := update.Message.ReplyToMessage
+	if replied == nil || replied.Document == nil {
+		reply(fbanImportUsage)
+		return
+	}
+
+	data, err := botapi.DownloadFile(ctx, hebeBot, config.AppConfig.ApiEndpoint, config.AppConfig.BotToken, replied.Document.FileID, fbanImportMaxSize)
+	if err == botapi.ErrFileTooBig {
+		reply(fbanTooBigMessage)
+		return
+	}
+	if err != nil {
+		logger.Sugar().Errorf("Unable to download federation bans :%s", err)
+		reply(fbanDownloadError)
+		return
+	}
+
+	added, err := federation.Import(data)
+	if err != nil {
+		reply(fmt.Sprintf(fbanImportError, err))
+		return
+	}
+	logger.Sugar().Infof("%d federation bans imported by %d", added, update.Message.From.ID)
+	reply(fmt.Sprintf(fbanImportedMessage, added))
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/permissions"
+	"github.com/hestingames/hg-hebe-bot/bot/router"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+)
+
+func helpCommand(r *router.Router) router.Command {
+	return router.Command{
+		Name:        "help",
+		Aliases:     []string{"ayuda"},
+		Description: "Muestra los comandos disponibles",
+		Localized:   map[string]string{"en": "Shows the available commands"},
+		Scope:       router.ScopeAll,
+		Handler: func(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
+			HandleHelp(ctx, logger, hebeBot, update, r.Commands())
+		},
+	}
+}
+
+func HandleHelp(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update, commands []router.Command) {
+	chatId := update.Message.Chat.ID
+
+	var text strings.Builder
+	text.WriteString("*Comandos disponibles*:\n\n")
+	for _, command := range commands {
+		// Only list the commands that can be used in this chat
+		if !command.Scope.AllowsChat(update.Message.Chat) {
+			continue
+		}
+		// Owner commands are only listed to the owners
+		if command.Scope.Has(router.ScopeOwner) && !permissions.IsOwner(update.Message.From.ID) {
+			continue
+		}
+		text.WriteString(fmt.Sprintf("/%s - %s\n", command.Name, command.Description))
+	}
+
+	msg := tgbotapi.NewMessage(chatId, text.String())
+	msg.ReplyToMessageID = update.Message.MessageID
+	msg.ParseMode = "markdown"
+
+	if _, err := hebeBot.Send(msg); err != nil {
+		logger.Sugar().Errorf("Unable to send help :%s", err)
+	}
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"context"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/raid"
+	"github.com/hestingames/hg-hebe-bot/bot/router"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+)
+
+const (
+	notLockedMessage   = "🤷🏻‍♀️ El grupo no está bloqueado"
+	unlockErrorMessage = "😅 No se pudo levantar el bloqueo, compruebe que el bot es administrador del grupo"
+)
+
+func init() {
+	register(router.Command{
+		Name:        "unlock",
+		Aliases:     []string{"desbloquear"},
+		Description: "Levanta el bloqueo del grupo",
+		Localized:   map[string]string{"en": "Lifts the group lockdown"},
+		Scope:       router.ScopeGroup | router.ScopeAdmin,
+		Handler:     HandleUnlock,
+	})
+}
+
+// HandleUnlock lifts the lockdown of the chat before the quiet period ends.
+func HandleUnlock(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
+	reply := replier(logger, hebeBot, update.Message)
+
+	// The lift is announced by raid.Lift
+	switch err := raid.Lift(hebeBot, update.Message.Chat.ID, *update.Message.From); err {
+	case nil:
+	case raid.ErrNotLocked:
+		reply(notLockedMessage)
+	default:
+		logger.Sugar().Errorf("Unable to lift lockdown :%s", err)
+		reply(unlockErrorMessage)
+	}
+}
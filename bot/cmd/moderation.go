@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/moderation"
+	"github.com/hestingames/hg-hebe-bot/bot/router"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+)
+
+const (
+	moderationErrorMessage = "😅 No se pudo completar la acción, compruebe que el bot es administrador del grupo"
+	noReasonMessage        = "no especificado"
+)
+
+// moderationCommand describes each moderation command
+type moderationCommand struct {
+	kind        moderation.Kind
+	aliases     []string
+	description string
+	english     string
+	usage       string
+	timed       bool   // Accepts a duration
+	done        string // Confirmation, formatted with the target name
+}
+
+var moderationCommands = []moderationCommand{
+	{
+		kind:        moderation.Ban,
+		aliases:     []string{"banear"},
+		description: "Expulsa a un usuario y le impide volver",
+		english:     "Bans a user from the group",
+		usage:       "/ban <@usuario|ID> [duración] [motivo]",
+		timed:       true,
+		done:        "🔨 %s ha sido baneado del grupo",
+	},
+	{
+		kind:        moderation.Kick,
+		aliases:     []string{"expulsar"},
+		description: "Expulsa a un usuario del grupo",
+		english:     "Kicks a user from the group",
+		usage:       "/kick <@usuario|ID> [motivo]",
+		done:        "👢 %s ha sido expulsado del grupo",
+	},
+	{
+		kind:        moderation.Mute,
+		aliases:     []string{"silenciar"},
+		description: "Impide a un usuario escribir en el grupo",
+		english:     "Mutes a user",
+		usage:       "/mute <@usuario|ID> [duración] [motivo]",
+		timed:       true,
+		done:        "🔇 %s ha sido silenciado",
+	},
+	{
+		kind:        moderation.Unmute,
+		description: "Permite a un usuario silenciado volver a escribir",
+		english:     "Unmutes a user",
+		usage:       "/unmute <@usuario|ID> [motivo]",
+		done:        "🔊 %s puede volver a escribir",
+	},
+	{
+		kind:        moderation.Unban,
+		description: "Permite a un usuario baneado volver al grupo",
+		english:     "Unbans a user",
+		usage:       "/unban <@usuario|ID> [motivo]",
+		done:        "✅ %s puede volver a unirse al grupo",
+	},
+}
+
+func init() {
+	for _, mc := range moderationCommands {
+		mc := mc
+		register(router.Command{
+			Name:        string(mc.kind),
+			Aliases:     mc.aliases,
+			Description: mc.description,
+			Localized:   map[string]string{"en": mc.english},
+			Scope:       router.ScopeGroup | router.ScopeAdmin,
+			Handler: func(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
+				HandleModeration(ctx, logger, hebeBot, update, mc)
+			},
+		})
+	}
+}
+
+// HandleModeration applies a moderation command on the replied user or the
+// user given as argument, followed by an optional duration and the reason.
+func HandleModeration(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update, mc moderationCommand) {
+	message := update.Message
+	reply := replier(logger, hebeBot, message)
+
+	if ok, err := canRestrict(hebeBot, message.Chat.ID, message.From); err != nil {
+		logger.Sugar().Errorf("Unable to check admin rights :%s", err)
+		reply(moderationErrorMessage)
+		return
+	} else if !ok {
+		reply(cannotRestrictMessage)
+		return
+	}
+
+	target, args, err := commandTarget(hebeBot, message)
+	if err == nil {
+		err = checkTarget(hebeBot, message.Chat.ID, target)
+	}
+	if err != nil {
+		if text, ok := userMessage(err); ok {
+			reply(text + "\nUso: " + mc.usage)
+		} else {
+			logger.Sugar().Errorf("Unable to resolve moderation target :%s", err)
+			reply(moderationErrorMessage)
+		}
+		return
+	}
+
+	var duration time.Duration
+	if mc.timed && len(args) != 0 {
+		if d, err := moderation.ParseDuration(args[0]); err == nil {
+			duration = d
+			args = args[1:]
+		}
+	}
+
+	action := moderation.Action{
+		Kind:   mc.kind,
+		ChatID: message.Chat.ID,
+		Target: target,
+		Admin:  *message.From,
+		Reason: strings.Join(args, " "),
+	}
+	if duration > 0 {
+		action.Until = time.Now().Add(duration)
+	}
+
+	if err := moderation.Apply(logger, hebeBot, action); err != nil {
+		reply(moderationErrorMessage)
+		return
+	}
+
+	reply(confirmation(mc.done, action))
+}
+
+// replier returns a function replying to message with plain text.
+func replier(logger *logs.Logger, hebeBot botapi.Bot, message *tgbotapi.Message) func(text string) {
+	return func(text string) {
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		msg.ReplyToMessageID = message.MessageID
+		if _, err := hebeBot.Send(msg); err != nil {
+			logger.Sugar().Errorf("Unable to send reply :%s", err)
+		}
+	}
+}
+
+// confirmation builds the message confirming a moderation action.
+func confirmation(done string, action moderation.Action) string {
+	text := fmt.Sprintf(done, moderation.UserName(action.Target))
+	if !action.Until.IsZero() {
+		text += " durante " + moderation.FormatDuration(time.Until(action.Until).Round(time.Second))
+	}
+
+	reason := action.Reason
+	if reason == "" {
+		reason = noReasonMessage
+	}
+	return text + fmt.Sprintf("\n📝 Motivo: %s\n👮🏻 Administrador: %s", reason, moderation.UserName(action.Admin))
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/audit"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/callback"
+	"github.com/hestingames/hg-hebe-bot/bot/permissions"
+	"github.com/hestingames/hg-hebe-bot/bot/router"
+	"github.com/hestingames/hg-hebe-bot/bot/users"
+	"github.com/hestingames/hg-hebe-bot/config"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+)
+
+const (
+	modlogPageSize     = 5
+	modlogEmptyMessage = "📋 No hay acciones de moderación registradas"
+	modlogErrorMessage = "😅 Ha ocurrido un error al consultar el registro de moderación"
+	modlogUsage        = "Uso: /modlog [@usuario|ID]"
+	modlogAdminMessage = "🙅🏻‍♀️ Lo siento, el registro de moderación solo está disponible para los administradores de los grupos"
+)
+
+func init() {
+	register(router.Command{
+		Name:        "modlog",
+		Description: "Registro de las acciones de moderación",
+		Localized:   map[string]string{"en": "Moderation log"},
+		Scope:       router.ScopePrivate | router.ScopeAdmin,
+		Handler:     HandleModlog,
+	})
+	registerCallback(router.Callback{
+		Namespace: "modlog",
+		Scope:     router.ScopePrivate,
+		Handler:   HandleModlogPage,
+	})
+}
+
+// HandleModlog shows the moderation log of the chats the user administrates,
+// optionally only the actions on a user.
+func HandleModlog(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
+	reply := replier(logger, hebeBot, update.Message)
+
+	var target int64
+	if arg := strings.TrimSpace(update.Message.CommandArguments()); arg != "" {
+		if user, ok := users.Lookup(arg); ok {
+			target = user.ID
+		} else if id, err := strconv.ParseInt(arg, 10, 64); err == nil {
+			target = id
+		} else {
+			reply(targetUnknownMessage + "\n" + modlogUsage)
+			return
+		}
+	}
+
+	text, keyboard, err := modlogPage(logger, hebeBot, update.Message.From.ID, target, 0)
+	if err == errNotModerator {
+		reply(modlogAdminMessage)
+		return
+	}
+	if err != nil {
+		logger.Sugar().Errorf("Unable to query the moderation log :%s", err)
+		reply(modlogErrorMessage)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+	if keyboard != nil {
+		msg.ReplyMarkup = keyboard
+	}
+	if _, err := hebeBot.Send(msg); err != nil {
+		logger.Sugar().Errorf("Unable to send the moderation log :%s", err)
+	}
+}
+
+// HandleModlogPage moves the moderation log message to another page.
+func HandleModlogPage(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, query *tgbotapi.CallbackQuery, data callback.Data) router.Answer {
+	target, _ := strconv.ParseInt(data.Arg(0), 10, 64)
+	page, _ := strconv.Atoi(data.Arg(1))
+
+	text, keyboard, err := modlogPage(logger, hebeBot, query.From.ID, target, page)
+	if err == errNotModerator {
+		return router.Answer{Text: modlogAdminMessage, Alert: true}
+	}
+	if err == nil {
+		err = callback.EditText(hebeBot, query, text, "", keyboard)
+	}
+	if err != nil {
+		logger.Sugar().Errorf("Unable to show the moderation log page :%s", err)
+		return router.Answer{Text: modlogErrorMessage}
+	}
+	return router.Answer{}
+}
+
+// modlogPage builds a page of the moderation log as seen by the user.
+func modlogPage(logger *logs.Logger, hebeBot botapi.Bot, userId, target int64, page int) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	visible, err := modlogChats(logger, hebeBot, userId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	entries, err := audit.Query(func(entry audit.Entry) bool {
+		return visible(entry.ChatID) && (target == 0 || entry.TargetID == target)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if len(entries) == 0 {
+		return modlogEmptyMessage, nil, nil
+	}
+
+	pages := (len(entries) + modlogPageSize - 1) / modlogPageSize
+	if page < 0 {
+		page = 0
+	}
+	if page >= pages {
+		page = pages - 1
+	}
+
+	var text strings.Builder
+	text.WriteString("📋 Registro de moderación")
+	if target != 0 {
+		fmt.Fprintf(&text, " de %d", target)
+	}
+	fmt.Fprintf(&text, " (%d/%d)\n", page+1, pages)
+
+	end := (page + 1) * modlogPageSize
+	if end > len(entries) {
+		end = len(entries)
+	}
+	for _, entry := range entries[page*modlogPageSize : end] {
+		fmt.Fprintf(&text, "\n#%d %s\n", entry.ID, audit.Format(entry))
+	}
+
+	var row []tgbotapi.InlineKeyboardButton
+	targetArg := strconv.FormatInt(target, 10)
+	if page > 0 {
+		row = append(row, callback.Button("◀️ Anterior", "modlog", targetArg, strconv.Itoa(page-1)))
+	}
+	if page < pages-1 {
+		row = append(row, callback.Button("Siguiente ▶️", "modlog", targetArg, strconv.Itoa(page+1)))
+	}
+	if len(row) == 0 {
+		return text.String(), nil, nil
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(row)
+	return text.String(), &keyboard, nil
+}
+
+// errNotModerator is returned to users that do not administrate any chat
+var errNotModerator = errors.New("user does not administrate any chat")
+
+// modlogChats returns whether the user can see the log of a chat: owners
+// see every chat, admins the allowed chats they administrate. The chats whose
+// administrators can not be checked are left out, it only fails when none
+// of them could be checked.
+func modlogChats(logger *logs.Logger, hebeBot botapi.Bot, userId int64) (func(chatId int64) bool, error) {
+	if permissions.IsOwner(userId) {
+		return func(int64) bool { return true }, nil
+	}
+
+	chats := make(map[int64]bool)
+	checked := 0
+	var lastErr error
+	for _, chat := range config.AppConfig.Chats.Get().([]config.ChatConfig) {
+		admin, err := permissions.IsAdmin(hebeBot, chat.ID, userId)
+		if err != nil {
+			logger.Sugar().Errorf("Unable to get the administrators of %d :%s", chat.ID, err)
+			lastErr = err
+			continue
+		}
+		checked++
+		if admin {
+			chats[chat.ID] = true
+		}
+	}
+	if checked == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	if len(chats) == 0 {
+		return nil, errNotModerator
+	}
+	return func(chatId int64) bool { return chats[chatId] }, nil
+}
@@ -0,0 +1,300 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/callback"
+	"github.com/hestingames/hg-hebe-bot/bot/moderation"
+	"github.com/hestingames/hg-hebe-bot/bot/permissions"
+	"github.com/hestingames/hg-hebe-bot/bot/reports"
+	"github.com/hestingames/hg-hebe-bot/bot/router"
+	"github.com/hestingames/hg-hebe-bot/bot/users"
+	"github.com/hestingames/hg-hebe-bot/config"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+)
+
+const (
+	reportUsage             = "🤷🏻‍♀️ Responda con /report al mensaje que quiera reportar"
+	reportCooldownMessage   = "⏳ Espere un poco antes de volver a reportar"
+	reportDuplicatedMessage = "👀 Ese mensaje ya ha sido reportado"
+	reportSentMessage       = "✅ Gracias, los administradores han sido notificados"
+	reportNoStaffMessage    = "😅 No hay administradores disponibles para recibir el reporte"
+	reportErrorMessage      = "😅 Ha ocurrido un error al enviar el reporte"
+	reportHandledMessage    = "✅ Este reporte ya ha sido gestionado"
+	reportAdminOnlyMessage  = "🙅🏻‍♀️ Lo siento, solo los administradores del grupo pueden gestionar este reporte"
+	reportDoneMessage       = "✅ Hecho"
+	reportDeleteError       = "😅 No se pudo borrar el mensaje"
+)
+
+// Mute of the report actions when ReportMuteDuration is not valid
+const reportMuteFallback = time.Hour
+
+// Report actions
+const (
+	reportDelete  = "delete"
+	reportWarn    = "warn"
+	reportMute    = "mute"
+	reportDismiss = "dismiss"
+)
+
+var reportActionLabels = map[string]string{
+	reportDelete:  "🗑 Mensaje eliminado",
+	reportWarn:    "⚠️ Usuario advertido",
+	reportMute:    "🔇 Usuario silenciado",
+	reportDismiss: "👌 Reporte descartado",
+}
+
+func init() {
+	register(router.Command{
+		Name:        "report",
+		Aliases:     []string{"reportar"},
+		Description: "Reporta a los administradores el mensaje respondido",
+		Localized:   map[string]string{"en": "Reports the replied message to the admins"},
+		Scope:       router.ScopeGroup,
+		Handler:     HandleReport,
+	})
+	registerCallback(router.Callback{
+		Namespace: "report",
+		Scope:     router.ScopeAll,
+		Handler:   HandleReportAction,
+	})
+}
+
+// HandleReport sends the replied message to the staff chat, or privately to
+// the admins that started the bot, with buttons to act on it.
+func HandleReport(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
+	message := update.Message
+	reply := replier(logger, hebeBot, message)
+
+	reported := message.ReplyToMessage
+	if reported == nil || reported.From == nil {
+		reply(reportUsage)
+		return
+	}
+	if err := checkTarget(hebeBot, message.Chat.ID, *reported.From); err != nil {
+		if text, ok := userMessage(err); ok {
+			reply(text)
+		} else {
+			logger.Sugar().Errorf("Unable to check reported user :%s", err)
+			reply(reportErrorMessage)
+		}
+		return
+	}
+
+	report, err := reports.Create(reports.Report{
+		ChatID:    message.Chat.ID,
+		ChatTitle: message.Chat.Title,
+		MessageID: reported.MessageID,
+		Target:    *reported.From,
+		Reporter:  *message.From,
+		Reason:    message.CommandArguments(),
+	})
+	switch err {
+	case nil:
+	case reports.ErrCooldown:
+		reply(reportCooldownMessage)
+		return
+	case reports.ErrDuplicated:
+		reply(reportDuplicatedMessage)
+		return
+	default:
+		logger.Sugar().Errorf("Unable to save report :%s", err)
+		reply(reportErrorMessage)
+		return
+	}
+
+	destinations, err := reportDestinations(hebeBot, message.Chat.ID)
+	if err != nil {
+		logger.Sugar().Errorf("Unable to get report destinations :%s", err)
+	}
+
+	text := reportText(report)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callback.Button("🗑 Borrar", "report", reportDelete, report.ID),
+			callback.Button("⚠️ Advertir", "report", reportWarn, report.ID),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			callback.Button("🔇 Silenciar", "report", reportMute, report.ID),
+			callback.Button("👌 Descartar", "report", reportDismiss, report.ID),
+		),
+	)
+
+	var notices []reports.Notice
+	for _, chatId := range destinations {
+		msg := tgbotapi.NewMessage(chatId, text)
+		msg.ReplyMarkup = keyboard
+		// The notice replies to a copy of the reported message
+		if forwarded, err := hebeBot.Send(tgbotapi.NewForward(chatId, message.Chat.ID, reported.MessageID)); err == nil {
+			msg.ReplyToMessageID = forwarded.MessageID
+		}
+		sent, err := hebeBot.Send(msg)
+		if err != nil {
+			logger.Sugar().Debugf("Unable to send report to %d :%s", chatId, err)
+			continue
+		}
+		notices = append(notices, reports.Notice{ChatID: chatId, MessageID: sent.MessageID})
+	}
+
+	if len(notices) == 0 {
+		reports.Resolve(report.ID)
+		reply(reportNoStaffMessage)
+		return
+	}
+	if err := reports.SetNotices(report.ID, notices); err != nil {
+		logger.Sugar().Errorf("Unable to save report notices :%s", err)
+	}
+	logger.Sugar().Infof("Message %d of chat %d reported by %d", reported.MessageID, message.Chat.ID, message.From.ID)
+	reply(reportSentMessage)
+}
+
+// HandleReportAction acts on a report as chosen by an admin of the chat the
+// report comes from.
+func HandleReportAction(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, query *tgbotapi.CallbackQuery, data callback.Data) router.Answer {
+	action, id := data.Arg(0), data.Arg(1)
+
+	report, err := reports.Get(id)
+	if err == reports.ErrNotFound {
+		callback.EditMarkup(hebeBot, query, nil)
+		return router.Answer{Text: reportHandledMessage}
+	}
+	if err != nil {
+		logger.Sugar().Errorf("Unable to get report :%s", err)
+		return router.Answer{Text: reportErrorMessage}
+	}
+
+	if admin, err := permissions.IsAdmin(hebeBot, report.ChatID, query.From.ID); err != nil || !admin {
+		return router.Answer{Text: reportAdminOnlyMessage, Alert: true}
+	}
+	// Warnings escalate to mutes and bans, so both need the restrict right
+	if action == reportWarn || action == reportMute {
+		if ok, err := canRestrict(hebeBot, report.ChatID, query.From); err != nil || !ok {
+			return router.Answer{Text: cannotRestrictMessage, Alert: true}
+		}
+	}
+
+	// Only the first admin acting on the report gets it. It is put back
+	// when the action fails, so it can be handled again
+	if report, err = reports.Resolve(id); err != nil {
+		return router.Answer{Text: reportHandledMessage}
+	}
+	failed := func(text string) router.Answer {
+		if err := reports.Restore(report); err != nil {
+			logger.Sugar().Errorf("Unable to restore report :%s", err)
+		}
+		return router.Answer{Text: text, Alert: true}
+	}
+
+	admin := *query.From
+	reason := "Reporte"
+	if report.Reason != "" {
+		reason += ": " + report.Reason
+	}
+	announce := func(text string) {
+		if _, err := hebeBot.Send(tgbotapi.NewMessage(report.ChatID, text)); err != nil {
+			logger.Sugar().Errorf("Unable to announce report action :%s", err)
+		}
+	}
+
+	switch action {
+	case reportDelete:
+		if _, err := hebeBot.Request(tgbotapi.NewDeleteMessage(report.ChatID, report.MessageID)); err != nil {
+			logger.Sugar().Errorf("Unable to delete reported message :%s", err)
+			return failed(reportDeleteError)
+		}
+		moderation.Record(logger, hebeBot, moderation.Action{
+			Kind:   moderation.Delete,
+			ChatID: report.ChatID,
+			Target: report.Target,
+			Admin:  admin,
+			Reason: reason,
+		})
+	case reportWarn:
+		if err := warnUser(logger, hebeBot, announce, report.ChatID, report.Target, admin, reason); err != nil {
+			return failed(warnsErrorMessage)
+		}
+	case reportMute:
+		mute := moderation.Action{
+			Kind:   moderation.Mute,
+			ChatID: report.ChatID,
+			Target: report.Target,
+			Admin:  admin,
+			Reason: reason,
+		}
+		duration, err := moderation.ParseDuration(config.AppConfig.ReportMuteDuration.Get())
+		if err != nil {
+			logger.Sugar().Errorf("Invalid report mute duration :%s", err)
+			duration = reportMuteFallback
+		}
+		mute.Until = time.Now().Add(duration)
+		if err := moderation.Apply(logger, hebeBot, mute); err != nil {
+			return failed(moderationErrorMessage)
+		}
+		announce(confirmation(moderationDone(mute.Kind), mute))
+	case reportDismiss:
+		logger.Sugar().Infof("Report %s dismissed by %d", report.ID, admin.ID)
+	default:
+		return router.Answer{}
+	}
+
+	// Tell every admin the report was handled
+	handled := fmt.Sprintf("%s\n\n%s por %s", reportText(report), reportActionLabels[action], moderation.UserName(admin))
+	for _, notice := range report.Notices {
+		hebeBot.Enqueue(tgbotapi.NewEditMessageText(notice.ChatID, notice.MessageID, handled))
+	}
+	return router.Answer{Text: reportDoneMessage}
+}
+
+// reportDestinations returns the staff chat, or the admins of the chat that
+// started the bot when there is none.
+func reportDestinations(hebeBot botapi.Bot, chatId int64) ([]int64, error) {
+	if staff := config.AppConfig.StaffChat.Get(); staff != 0 {
+		return []int64{staff}, nil
+	}
+
+	admins, err := permissions.Admins(hebeBot, chatId)
+	if err != nil {
+		return nil, err
+	}
+	var destinations []int64
+	for _, admin := range admins {
+		if admin.User != nil && !admin.User.IsBot && users.Started(admin.User.ID) {
+			destinations = append(destinations, admin.User.ID)
+		}
+	}
+	return destinations, nil
+}
+
+// reportText describes the report for the admins.
+func reportText(report reports.Report) string {
+	var text strings.Builder
+	chatName := report.ChatTitle
+	if chatName == "" {
+		chatName = strconv.FormatInt(report.ChatID, 10)
+	}
+	fmt.Fprintf(&text, "🚩 Reporte en %s\n", chatName)
+	fmt.Fprintf(&text, "👤 Reportado: %s [%d]\n", moderation.UserName(report.Target), report.Target.ID)
+	fmt.Fprintf(&text, "🙋 Reportado por: %s\n", moderation.UserName(report.Reporter))
+	if report.Reason != "" {
+		fmt.Fprintf(&text, "📝 Motivo: %s\n", report.Reason)
+	}
+	if link := messageLink(report.ChatID, report.MessageID); link != "" {
+		fmt.Fprintf(&text, "🔗 %s\n", link)
+	}
+	return strings.TrimSuffix(text.String(), "\n")
+}
+
+// messageLink returns the link to a message of a supergroup.
+func messageLink(chatId int64, messageId int) string {
+	id := strconv.FormatInt(chatId, 10)
+	if !strings.HasPrefix(id, "-100") {
+		return ""
+	}
+	return fmt.Sprintf("https://t.me/c/%s/%d", strings.TrimPrefix(id, "-100"), messageId)
+}
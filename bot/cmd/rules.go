@@ -1,7 +1,11 @@
 package cmd
 
 import (
+	"context"
+
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/router"
 	"github.com/hestingames/hg-hebe-bot/internal/logs"
 )
 
@@ -13,9 +17,20 @@ const ChannelRules = "*Normas del Grupo*:\n\n" +
 	"Consulte además las [Reglas del Servicio](https://csgo.hestingames.nat.cu/rules) antes de jugar en nuestros servidores de Counter-Strike: Global Offensive\n\n" +
 	"El equipo de administración de HestinGames se reserva el derecho de tomar acciones administrativas ante cualquier comportamiento tóxico, radiactivo o inapropiado. Así como la prohibición de entrada al grupo a usuarios no deseados.\n"
 
-func HandleRules(logger *logs.Logger, hebeBot tgbotapi.BotAPI, update tgbotapi.Update) {
+func init() {
+	register(router.Command{
+		Name:        "rules",
+		Aliases:     []string{"normas", "reglas"},
+		Description: "Normas del grupo",
+		Localized:   map[string]string{"en": "Group rules"},
+		Scope:       router.ScopeAll,
+		Handler:     HandleRules,
+	})
+}
+
+func HandleRules(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
 	chatId := update.Message.Chat.ID
-	hebeBot.Send(tgbotapi.NewChatAction(chatId, tgbotapi.ChatTyping))
+	hebeBot.Enqueue(tgbotapi.NewChatAction(chatId, tgbotapi.ChatTyping))
 
 	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
 	msg.ReplyToMessageID = update.Message.MessageID
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/permissions"
+	"github.com/hestingames/hg-hebe-bot/bot/users"
+)
+
+const (
+	targetMissingMessage  = "🤷🏻‍♀️ Responda al mensaje del usuario o indique su @usuario o ID"
+	targetUnknownMessage  = "🤷🏻‍♀️ No conozco a ese usuario, responda a uno de sus mensajes o indique su ID"
+	targetAdminMessage    = "🙅🏻‍♀️ No puedo aplicar esa acción a un administrador"
+	targetSelfMessage     = "🙅🏻‍♀️ No puedo aplicar esa acción sobre mí misma"
+	cannotRestrictMessage = "🙅🏻‍♀️ Lo siento, no tiene permiso para restringir miembros del grupo"
+)
+
+// targetError is returned when the target of a command can not be resolved,
+// its message is meant for the user.
+type targetError string
+
+func (e targetError) Error() string {
+	return string(e)
+}
+
+// commandTarget resolves the user a moderation command is about: the author
+// of the replied message, or the user given as first argument by @username,
+// mention or ID. It returns the remaining arguments.
+func commandTarget(hebeBot botapi.Bot, message *tgbotapi.Message) (tgbotapi.User, []string, error) {
+	args := strings.Fields(message.CommandArguments())
+
+	if reply := message.ReplyToMessage; reply != nil && reply.From != nil {
+		return *reply.From, args, nil
+	}
+
+	if len(args) == 0 {
+		return tgbotapi.User{}, nil, targetError(targetMissingMessage)
+	}
+
+	// Users without username are mentioned by name
+	if user, rest, ok := mentionTarget(message); ok {
+		return user, rest, nil
+	}
+
+	if strings.HasPrefix(args[0], "@") {
+		if user, ok := users.Lookup(args[0]); ok {
+			return user, args[1:], nil
+		}
+		return tgbotapi.User{}, nil, targetError(targetUnknownMessage)
+	}
+
+	userId, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return tgbotapi.User{}, nil, targetError(targetMissingMessage)
+	}
+	// Get the user details when it is still in the chat
+	user := tgbotapi.User{ID: userId}
+	if member, err := botapi.GetChatMember(hebeBot, message.Chat.ID, userId); err == nil && member.User != nil {
+		user = *member.User
+	}
+	return user, args[1:], nil
+}
+
+// mentionTarget returns the user mentioned by name right after the command,
+// along with the arguments following the mention. Names may have spaces, so
+// the arguments are cut at the end of the mention entity.
+func mentionTarget(message *tgbotapi.Message) (tgbotapi.User, []string, bool) {
+	if !message.IsCommand() {
+		return tgbotapi.User{}, nil, false
+	}
+	// Entity offsets are in UTF-16 code units
+	text := utf16.Encode([]rune(message.Text))
+
+	start := message.Entities[0].Length
+	for start < len(text) && unicode.IsSpace(rune(text[start])) {
+		start++
+	}
+
+	for _, entity := range message.Entities {
+		end := entity.Offset + entity.Length
+		if entity.Type == "text_mention" && entity.User != nil && entity.Offset == start && end <= len(text) {
+			return *entity.User, strings.Fields(string(utf16.Decode(text[end:]))), true
+		}
+	}
+	return tgbotapi.User{}, nil, false
+}
+
+// checkTarget refuses moderation actions on the bot itself and on the chat
+// administrators.
+func checkTarget(hebeBot botapi.Bot, chatId int64, target tgbotapi.User) error {
+	if target.ID == hebeBot.Self().ID {
+		return targetError(targetSelfMessage)
+	}
+	admin, err := permissions.IsAdmin(hebeBot, chatId, target.ID)
+	if err != nil {
+		return err
+	}
+	if admin {
+		return targetError(targetAdminMessage)
+	}
+	return nil
+}
+
+// canRestrict reports whether the user can restrict the members of the chat.
+func canRestrict(hebeBot botapi.Bot, chatId int64, user *tgbotapi.User) (bool, error) {
+	if user == nil {
+		return false, nil
+	}
+	if permissions.IsOwner(user.ID) {
+		return true, nil
+	}
+	member, ok, err := permissions.Admin(hebeBot, chatId, user.ID)
+	if err != nil || !ok {
+		return false, err
+	}
+	return member.IsCreator() || member.CanRestrictMembers, nil
+}
+
+// userMessage returns the message of err meant for the user, if any.
+func userMessage(err error) (string, bool) {
+	var target targetError
+	if errors.As(err, &target) {
+		return string(target), true
+	}
+	return "", false
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// mentionMessage builds a command message mentioning user by name at the
+// first occurrence of name.
+func mentionMessage(text, name string, user tgbotapi.User) *tgbotapi.Message {
+	units := utf16.Encode([]rune(text))
+	command := len(utf16.Encode([]rune("/mute")))
+	entities := []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: command}}
+
+	nameUnits := utf16.Encode([]rune(name))
+	for i := 0; i+len(nameUnits) <= len(units); i++ {
+		if string(utf16.Decode(units[i:i+len(nameUnits)])) == name {
+			entities = append(entities, tgbotapi.MessageEntity{Type: "text_mention", Offset: i, Length: len(nameUnits), User: &user})
+			break
+		}
+	}
+	return &tgbotapi.Message{Text: text, Entities: entities, Chat: &tgbotapi.Chat{ID: -100}}
+}
+
+func TestCommandTargetMention(t *testing.T) {
+	john := tgbotapi.User{ID: 42, FirstName: "John", LastName: "Smith"}
+
+	tests := []struct {
+		name     string
+		message  *tgbotapi.Message
+		wantUser int64
+		wantArgs []string
+		wantErr  bool
+	}{
+		{
+			name:     "name with spaces",
+			message:  mentionMessage("/mute John Smith 2h spam", "John Smith", john),
+			wantUser: 42,
+			wantArgs: []string{"2h", "spam"},
+		},
+		{
+			name:     "emoji before the mention",
+			message:  mentionMessage("/mute 🎮 John Smith 1d", "🎮 John Smith", john),
+			wantUser: 42,
+			wantArgs: []string{"1d"},
+		},
+		{
+			name:     "only the mention",
+			message:  mentionMessage("/mute John Smith", "John Smith", john),
+			wantUser: 42,
+			wantArgs: []string{},
+		},
+		{
+			// The mention is part of the reason, not the target
+			name:    "mention after the arguments",
+			message: mentionMessage("/mute 2h John Smith", "John Smith", john),
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			user, args, err := commandTarget(nil, test.message)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("got user %d, want an error", user.ID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.ID != test.wantUser {
+				t.Errorf("user = %d, want %d", user.ID, test.wantUser)
+			}
+			if len(args) != 0 || len(test.wantArgs) != 0 {
+				if !reflect.DeepEqual(args, test.wantArgs) {
+					t.Errorf("args = %q, want %q", args, test.wantArgs)
+				}
+			}
+		})
+	}
+}
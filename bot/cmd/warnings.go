@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/moderation"
+	"github.com/hestingames/hg-hebe-bot/bot/permissions"
+	"github.com/hestingames/hg-hebe-bot/bot/router"
+	"github.com/hestingames/hg-hebe-bot/bot/warnings"
+	"github.com/hestingames/hg-hebe-bot/config"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+)
+
+const (
+	warnUsage         = "/warn <@usuario|ID> [motivo]"
+	resetWarnsUsage   = "/resetwarns <@usuario|ID>"
+	warnsErrorMessage = "😅 Ha ocurrido un error al consultar las advertencias"
+	noWarnsMessage    = "✅ %s no tiene advertencias"
+	warnsResetMessage = "♻️ Las advertencias de %s han sido eliminadas"
+)
+
+func init() {
+	register(router.Command{
+		Name:        "warn",
+		Aliases:     []string{"advertir"},
+		Description: "Advierte a un usuario",
+		Localized:   map[string]string{"en": "Warns a user"},
+		Scope:       router.ScopeGroup | router.ScopeAdmin,
+		Handler:     HandleWarn,
+	})
+	register(router.Command{
+		Name:        "warns",
+		Aliases:     []string{"advertencias"},
+		Description: "Muestra las advertencias de un usuario",
+		Localized:   map[string]string{"en": "Shows the warnings of a user"},
+		Scope:       router.ScopeGroup,
+		Handler:     HandleWarns,
+	})
+	register(router.Command{
+		Name:        "resetwarns",
+		Description: "Elimina las advertencias de un usuario",
+		Localized:   map[string]string{"en": "Removes the warnings of a user"},
+		Scope:       router.ScopeGroup | router.ScopeAdmin,
+		Handler:     HandleResetWarns,
+	})
+}
+
+// HandleWarn warns the replied user or the user given as argument, and
+// escalates when the user reaches one of the warning thresholds of the chat.
+func HandleWarn(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
+	message := update.Message
+	reply := replier(logger, hebeBot, message)
+
+	// Warnings escalate to mutes and bans
+	if ok, err := canRestrict(hebeBot, message.Chat.ID, message.From); err != nil {
+		logger.Sugar().Errorf("Unable to check admin rights :%s", err)
+		reply(warnsErrorMessage)
+		return
+	} else if !ok {
+		reply(cannotRestrictMessage)
+		return
+	}
+
+	target, args, err := commandTarget(hebeBot, message)
+	if err == nil {
+		err = checkTarget(hebeBot, message.Chat.ID, target)
+	}
+	if err != nil {
+		if text, ok := userMessage(err); ok {
+			reply(text + "\nUso: " + warnUsage)
+		} else {
+			logger.Sugar().Errorf("Unable to resolve warning target :%s", err)
+			reply(warnsErrorMessage)
+		}
+		return
+	}
+
+	warnUser(logger, hebeBot, reply, message.Chat.ID, target, *message.From, strings.Join(args, " "))
+}
+
+// warnUser warns the target, tells the chat through reply and escalates when
+// the target reaches one of the warning thresholds of the chat. It only
+// fails when the warning could not be given.
+func warnUser(logger *logs.Logger, hebeBot botapi.Bot, reply func(string), chatId int64, target, admin tgbotapi.User, reason string) error {
+	warning := warnings.Warning{
+		ChatID:    chatId,
+		UserID:    target.ID,
+		AdminID:   admin.ID,
+		AdminName: moderation.UserName(admin),
+		Reason:    reason,
+		Time:      time.Now(),
+	}
+	list, err := warnings.Add(warning)
+	if err != nil {
+		logger.Sugar().Errorf("Unable to save warning :%s", err)
+		reply(warnsErrorMessage)
+		return err
+	}
+	moderation.Record(logger, hebeBot, moderation.Action{
+		Kind:   moderation.Warn,
+		ChatID: chatId,
+		Target: target,
+		Admin:  admin,
+		Reason: reason,
+	})
+
+	if reason == "" {
+		reason = noReasonMessage
+	}
+	text := fmt.Sprintf("⚠️ %s ha recibido una advertencia (%s)\n📝 Motivo: %s\n👮🏻 Administrador: %s",
+		moderation.UserName(target), warnCount(chatId, len(list)), reason, warning.AdminName)
+	reply(text)
+
+	action, escalated, err := warnings.Escalate(logger, hebeBot, chatId, target, len(list))
+	if err != nil {
+		// The warning itself was given
+		logger.Sugar().Errorf("Unable to escalate warnings :%s", err)
+		reply(moderationErrorMessage)
+		return nil
+	}
+	if escalated {
+		reply(confirmation(moderationDone(action.Kind), action))
+	}
+	return nil
+}
+
+// HandleWarns lists the warnings of the replied user or the user given as
+// argument. Members can only check their own warnings.
+func HandleWarns(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
+	message := update.Message
+	reply := replier(logger, hebeBot, message)
+
+	target := *message.From
+	if message.ReplyToMessage != nil || message.CommandArguments() != "" {
+		admin, err := permissions.IsAdmin(hebeBot, message.Chat.ID, message.From.ID)
+		if err != nil {
+			logger.Sugar().Errorf("Unable to check admin :%s", err)
+			reply(warnsErrorMessage)
+			return
+		}
+		if admin {
+			if target, _, err = commandTarget(hebeBot, message); err != nil {
+				if text, ok := userMessage(err); ok {
+					reply(text)
+				} else {
+					reply(warnsErrorMessage)
+				}
+				return
+			}
+		}
+	}
+
+	list, err := warnings.List(message.Chat.ID, target.ID)
+	if err != nil {
+		logger.Sugar().Errorf("Unable to get warnings :%s", err)
+		reply(warnsErrorMessage)
+		return
+	}
+	if len(list) == 0 {
+		reply(fmt.Sprintf(noWarnsMessage, moderation.UserName(target)))
+		return
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("⚠️ Advertencias de %s (%s):\n\n", moderation.UserName(target), warnCount(message.Chat.ID, len(list))))
+	for i, warning := range list {
+		reason := warning.Reason
+		if reason == "" {
+			reason = noReasonMessage
+		}
+		text.WriteString(fmt.Sprintf("%d. %s - %s (%s)\n", i+1, warning.Time.Format("02/01/2006 15:04"), reason, warning.AdminName))
+	}
+	reply(text.String())
+}
+
+// HandleResetWarns removes the warnings of the replied user or the user
+// given as argument.
+func HandleResetWarns(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
+	message := update.Message
+	reply := replier(logger, hebeBot, message)
+
+	target, _, err := commandTarget(hebeBot, message)
+	if err != nil {
+		if text, ok := userMessage(err); ok {
+			reply(text + "\nUso: " + resetWarnsUsage)
+		} else {
+			reply(warnsErrorMessage)
+		}
+		return
+	}
+
+	if err := warnings.Reset(message.Chat.ID, target.ID); err != nil {
+		logger.Sugar().Errorf("Unable to reset warnings :%s", err)
+		reply(warnsErrorMessage)
+		return
+	}
+	moderation.Record(logger, hebeBot, moderation.Action{
+		Kind:   moderation.ResetWarns,
+		ChatID: message.Chat.ID,
+		Target: target,
+		Admin:  *message.From,
+	})
+	reply(fmt.Sprintf(warnsResetMessage, moderation.UserName(target)))
+}
+
+// warnCount formats the number of warnings along with the warnings leading
+// to the last threshold of the chat.
+func warnCount(chatId int64, count int) string {
+	max := 0
+	for _, threshold := range config.AppConfig.WarnThresholds(chatId) {
+		if threshold.Warns > max {
+			max = threshold.Warns
+		}
+	}
+	if max == 0 {
+		return fmt.Sprintf("%d", count)
+	}
+	return fmt.Sprintf("%d/%d", count, max)
+}
+
+// moderationDone returns the confirmation of the moderation command of kind.
+func moderationDone(kind moderation.Kind) string {
+	for _, mc := range moderationCommands {
+		if mc.kind == kind {
+			return mc.done
+		}
+	}
+	return "%s"
+}
@@ -1,16 +1,73 @@
 package events
 
 import (
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"context"
 
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/hestingames/hg-hebe-bot/bot/actions"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/captcha"
+	"github.com/hestingames/hg-hebe-bot/bot/federation"
+	"github.com/hestingames/hg-hebe-bot/bot/permissions"
+	"github.com/hestingames/hg-hebe-bot/bot/raid"
+	"github.com/hestingames/hg-hebe-bot/config"
 	"github.com/hestingames/hg-hebe-bot/internal/logs"
 )
 
-func HandleNewChatMembers(logger *logs.Logger, hebeBot tgbotapi.BotAPI, update tgbotapi.Update) {
-	for i := range update.Message.NewChatMembers {
-		if !update.Message.NewChatMembers[i].IsBot {
-			actions.SayHello(logger, hebeBot, update.Message.Chat.ID, update.Message.NewChatMembers[i])
+// HandleNewChatMembers verifies the new members with a captcha, when enabled
+// in the chat, and welcomes them. Members added by an admin are trusted.
+// Joins are also checked against the federation ban list and for raids.
+func HandleNewChatMembers(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
+	chatId := update.Message.Chat.ID
+	chat, _ := config.AppConfig.Chat(chatId)
+
+	// Users in the federation ban list are banned right away
+	var members []tgbotapi.User
+	for _, member := range update.Message.NewChatMembers {
+		if !member.IsBot && !federation.Enforce(logger, hebeBot, chatId, member) {
+			members = append(members, member)
+		}
+	}
+	if len(members) == 0 {
+		return
+	}
+	// While locked down nothing is posted, it would flood the chat. The
+	// members that must solve a captcha are challenged once it is lifted
+	locked := raid.Joined(hebeBot, update.Message.Chat, len(members))
+
+	for _, member := range members {
+		if captcha.Enabled(chatId) && !addedByAdmin(logger, hebeBot, update.Message, member) {
+			if locked {
+				err := raid.Hold(hebeBot, chatId, member)
+				if err == nil {
+					continue
+				}
+				// Lifted meanwhile, the captcha can be posted right away
+				if err != raid.ErrNotLocked {
+					logger.Sugar().Errorf("Unable to hold new member :%s", err)
+					continue
+				}
+			}
+			// Welcomed once they pass the captcha
+			if err := captcha.Challenge(ctx, hebeBot, chatId, member); err != nil {
+				logger.Sugar().Errorf("Unable to challenge new member :%s", err)
+			}
+			continue
 		}
+
+		if chat.Welcome && !locked {
+			actions.SayHello(ctx, logger, hebeBot, chatId, member)
+		}
+	}
+}
+
+func addedByAdmin(logger *logs.Logger, hebeBot botapi.Bot, message *tgbotapi.Message, member tgbotapi.User) bool {
+	if message.From == nil || message.From.ID == member.ID {
+		return false
+	}
+	admin, err := permissions.IsAdmin(hebeBot, message.Chat.ID, message.From.ID)
+	if err != nil {
+		logger.Sugar().Errorf("Unable to check admin :%s", err)
 	}
+	return admin
 }
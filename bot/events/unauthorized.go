@@ -1,8 +1,13 @@
 package events
 
 import (
+	"context"
+
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/config"
 	"github.com/hestingames/hg-hebe-bot/internal/logs"
+	"go.uber.org/zap"
 )
 
 const (
@@ -11,17 +16,30 @@ const (
 		"💁🏻‍♀️ Si cree que eso puede ser un error, contace a [HestinGames](https://t.me/hestingames)"
 )
 
-func HandleUnauthorizedMessage(logger *logs.Logger, hebeBot tgbotapi.BotAPI, update tgbotapi.Update) {
+func HandleUnauthorizedMessage(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
 	chatId := update.Message.Chat.ID
+	action := config.AppConfig.UnauthorizedAction.Get()
+
+	logger.Info("Message from unauthorized chat",
+		zap.Int64("chat", chatId),
+		zap.String("title", update.Message.Chat.Title),
+		zap.String("action", action))
 
-	// Log attemp ?
+	switch action {
+	case config.UnauthorizedIgnore:
+		return
+	case config.UnauthorizedLeave:
+		if _, err := hebeBot.Request(tgbotapi.LeaveChatConfig{ChatID: chatId}); err != nil {
+			logger.Sugar().Errorf("Unable to leave the unauthorized chat :%s", err)
+		}
+		return
+	}
 
 	msg := tgbotapi.NewMessage(chatId, "")
 	msg.ParseMode = "markdown"
 	msg.Text = unauthorizedMessage
 
 	if _, err := hebeBot.Send(msg); err != nil {
-		logger.Sugar().Error("Unable to send the unauthorized message :%s", err)
+		logger.Sugar().Errorf("Unable to send the unauthorized message :%s", err)
 	}
-
 }
@@ -0,0 +1,209 @@
+// Package federation keeps the ban list shared by every allowed chat. A user
+// banned from the federation is banned from all the chats the bot
+// administrates, and banned again when joining any of them later.
+package federation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/moderation"
+	"github.com/hestingames/hg-hebe-bot/bot/permissions"
+	"github.com/hestingames/hg-hebe-bot/config"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+	"github.com/hestingames/hg-hebe-bot/internal/store"
+	"go.uber.org/zap"
+)
+
+// Store key of the federation ban list
+const bansKey = "federation.bans"
+
+// Version of the export format
+const exportVersion = 1
+
+var errNotInitialized = errors.New("federation not initialized")
+
+// Ban of the federation
+type Ban struct {
+	UserID    int64
+	UserName  string
+	Reason    string
+	AdminID   int64
+	AdminName string
+	Time      time.Time
+}
+
+// export is the JSON document shared with partner communities
+type export struct {
+	Version int
+	Bans    []Ban
+}
+
+var (
+	bans   *store.Store
+	bansMu sync.Mutex
+)
+
+// Initialize sets the store the ban list is persisted in.
+func Initialize(st *store.Store) {
+	bansMu.Lock()
+	bans = st
+	bansMu.Unlock()
+}
+
+// Banned returns the federation ban of the user, if any.
+func Banned(userId int64) (Ban, bool) {
+	list, err := load()
+	if err != nil {
+		return Ban{}, false
+	}
+	ban, ok := list[userId]
+	return ban, ok
+}
+
+// Add adds the ban to the list and bans the user from every allowed chat
+// the bot administrates. It returns the chats the user was banned from.
+func Add(logger *logs.Logger, hebeBot botapi.Bot, ban Ban, target, admin tgbotapi.User) (int, error) {
+	if err := update(func(list map[int64]Ban) { list[ban.UserID] = ban }); err != nil {
+		return 0, err
+	}
+	return apply(logger, hebeBot, moderation.Action{
+		Kind:   moderation.Ban,
+		Target: target,
+		Admin:  admin,
+		Reason: reason(ban.Reason),
+	}), nil
+}
+
+// Remove removes the user from the list and unbans it from every allowed
+// chat the bot administrates. It reports whether the user was banned.
+func Remove(logger *logs.Logger, hebeBot botapi.Bot, target, admin tgbotapi.User) (bool, error) {
+	found := false
+	err := update(func(list map[int64]Ban) {
+		_, found = list[target.ID]
+		delete(list, target.ID)
+	})
+	if err != nil || !found {
+		return found, err
+	}
+	apply(logger, hebeBot, moderation.Action{
+		Kind:   moderation.Unban,
+		Target: target,
+		Admin:  admin,
+		Reason: reason(""),
+	})
+	return true, nil
+}
+
+// Enforce bans a user joining a chat when it is in the list. It reports
+// whether the user was banned.
+func Enforce(logger *logs.Logger, hebeBot botapi.Bot, chatId int64, user tgbotapi.User) bool {
+	ban, ok := Banned(user.ID)
+	if !ok {
+		return false
+	}
+	err := moderation.Apply(logger, hebeBot, moderation.Action{
+		Kind:   moderation.Ban,
+		ChatID: chatId,
+		Target: user,
+		Admin:  hebeBot.Self(),
+		Reason: reason(ban.Reason),
+	})
+	return err == nil
+}
+
+// Export returns the ban list as a JSON document.
+func Export() ([]byte, error) {
+	list, err := load()
+	if err != nil {
+		return nil, err
+	}
+	doc := export{Version: exportVersion, Bans: make([]Ban, 0, len(list))}
+	for _, ban := range list {
+		doc.Bans = append(doc.Bans, ban)
+	}
+	sort.Slice(doc.Bans, func(i, j int) bool { return doc.Bans[i].Time.Before(doc.Bans[j].Time) })
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// Import adds the bans of a JSON document made by Export, keeping the bans
+// already in the list. It returns the number of bans added. Imported users
+// are banned when they join any of the chats.
+func Import(data []byte) (int, error) {
+	var doc export
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return 0, err
+	}
+	if doc.Version != exportVersion {
+		return 0, fmt.Errorf("unsupported ban list version %d", doc.Version)
+	}
+
+	added := 0
+	err := update(func(list map[int64]Ban) {
+		for _, ban := range doc.Bans {
+			if _, ok := list[ban.UserID]; ban.UserID == 0 || ok {
+				continue
+			}
+			list[ban.UserID] = ban
+			added++
+		}
+	})
+	return added, err
+}
+
+// apply takes the action on every allowed chat the bot administrates.
+func apply(logger *logs.Logger, hebeBot botapi.Bot, action moderation.Action) int {
+	applied := 0
+	for _, chat := range config.AppConfig.Chats.Get().([]config.ChatConfig) {
+		if _, admin, err := permissions.Admin(hebeBot, chat.ID, hebeBot.Self().ID); err != nil || !admin {
+			logger.Warn("Not an administrator, skipping federation action", zap.Int64("chat", chat.ID), zap.Error(err))
+			continue
+		}
+		action.ChatID = chat.ID
+		if moderation.Apply(logger, hebeBot, action) == nil {
+			applied++
+		}
+	}
+	return applied
+}
+
+func load() (map[int64]Ban, error) {
+	bansMu.Lock()
+	defer bansMu.Unlock()
+	return get()
+}
+
+// update runs fn on the ban list and saves it.
+func update(fn func(list map[int64]Ban)) error {
+	bansMu.Lock()
+	defer bansMu.Unlock()
+	list, err := get()
+	if err != nil {
+		return err
+	}
+	fn(list)
+	return bans.Put(bansKey, list)
+}
+
+// get loads the ban list. Must be called with bansMu held.
+func get() (map[int64]Ban, error) {
+	if bans == nil {
+		return nil, errNotInitialized
+	}
+	list := make(map[int64]Ban)
+	_, err := bans.Get(bansKey, &list)
+	return list, err
+}
+
+func reason(reason string) string {
+	if reason == "" {
+		return "Federación"
+	}
+	return "Federación: " + reason
+}
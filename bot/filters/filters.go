@@ -0,0 +1,238 @@
+// Package filters runs the anti-spam filters on the group messages. The
+// first filter matching a message decides what happens to it.
+package filters
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/moderation"
+	"github.com/hestingames/hg-hebe-bot/bot/permissions"
+	"github.com/hestingames/hg-hebe-bot/bot/warnings"
+	"github.com/hestingames/hg-hebe-bot/config"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+	"go.uber.org/zap"
+)
+
+const (
+	warnedMessage = "⚠️ %s ha recibido una advertencia\n📝 Motivo: %s"
+	mutedMessage  = "🔇 %s ha sido silenciado durante %s\n📝 Motivo: %s"
+)
+
+// sweepInterval is how often the state kept by the filters is cleaned up
+const sweepInterval = time.Minute
+
+// Users muted by the filters and until when. The messages they sent before
+// the mute took effect do not mute them again
+var (
+	muted   = make(map[floodKey]time.Time)
+	mutedMu sync.Mutex
+)
+
+// filter is a step of the pipeline
+type filter struct {
+	name   string // Used in the logs
+	reason string // Shown to the users
+	config func(cfg config.FiltersConfig) config.FilterConfig
+	match  func(cfg config.FiltersConfig, message *tgbotapi.Message) bool
+}
+
+var pipeline = []filter{
+	{
+		name:   "links",
+		reason: "Enlaces no permitidos",
+		config: func(cfg config.FiltersConfig) config.FilterConfig { return cfg.Links },
+		match:  matchLinks,
+	},
+	{
+		name:   "forwards",
+		reason: "Mensajes reenviados de canales",
+		config: func(cfg config.FiltersConfig) config.FilterConfig { return cfg.Forwards },
+		match:  matchForwards,
+	},
+	{
+		name:   "words",
+		reason: "Lenguaje no permitido",
+		config: func(cfg config.FiltersConfig) config.FilterConfig { return cfg.Words },
+		match:  matchWords,
+	},
+	{
+		name:   "emoji",
+		reason: "Exceso de emojis",
+		config: func(cfg config.FiltersConfig) config.FilterConfig { return cfg.Emoji },
+		match:  matchEmoji,
+	},
+	{
+		name:   "caps",
+		reason: "Exceso de mayúsculas",
+		config: func(cfg config.FiltersConfig) config.FilterConfig { return cfg.Caps },
+		match:  matchCaps,
+	},
+	{
+		name:   "flood",
+		reason: "Flood",
+		config: func(cfg config.FiltersConfig) config.FilterConfig { return cfg.Flood },
+		match:  matchFlood,
+	},
+}
+
+// Check runs the filters on a group message and acts on the first one
+// matching. It returns true when the message was removed.
+func Check(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, message *tgbotapi.Message) bool {
+	if message.Chat.IsPrivate() || message.From == nil || exempt(message) {
+		return false
+	}
+
+	cfg := config.AppConfig.Filters.Get().(config.FiltersConfig)
+	for _, f := range pipeline {
+		action := f.config(cfg).Action
+		if action == "" || !f.match(cfg, message) {
+			continue
+		}
+
+		// Admins are checked last, as it may need a request
+		if admin, err := permissions.IsAdmin(hebeBot, message.Chat.ID, message.From.ID); err != nil || admin {
+			if err != nil {
+				logger.Error("Unable to check admin", zap.Error(err))
+			}
+			return false
+		}
+
+		logger.Info("Message filtered",
+			zap.String("filter", f.name),
+			zap.String("action", action),
+			zap.Int64("chat", message.Chat.ID),
+			zap.Int64("user", message.From.ID))
+		apply(logger, hebeBot, message, action, f.reason, cfg)
+		return true
+	}
+	return false
+}
+
+// StartSweeping cleans up periodically the recent messages and mutes the
+// filters keep track of. The returned function stops it.
+func StartSweeping() func() {
+	ticker := time.NewTicker(sweepInterval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				sweep(now)
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+func sweep(now time.Time) {
+	sweepFlood(config.AppConfig.Filters.Get().(config.FiltersConfig), now)
+
+	mutedMu.Lock()
+	defer mutedMu.Unlock()
+	for key, until := range muted {
+		if !now.Before(until) {
+			delete(muted, key)
+		}
+	}
+}
+
+// isMuted reports whether the user is still muted by the filters.
+func isMuted(key floodKey) bool {
+	mutedMu.Lock()
+	defer mutedMu.Unlock()
+	return time.Now().Before(muted[key])
+}
+
+// exempt reports whether the message is not checked at all: messages sent
+// on behalf of the group by anonymous admins, and posts of the linked
+// channel automatically forwarded to the group.
+func exempt(message *tgbotapi.Message) bool {
+	if message.SenderChat != nil && message.SenderChat.ID == message.Chat.ID {
+		return true
+	}
+	return message.IsAutomaticForward
+}
+
+func apply(logger *logs.Logger, hebeBot botapi.Bot, message *tgbotapi.Message, action, reason string, cfg config.FiltersConfig) {
+	chatId := message.Chat.ID
+	hebeBot.Enqueue(tgbotapi.NewDeleteMessage(chatId, message.MessageID))
+	moderation.Record(logger, hebeBot, moderation.Action{
+		Kind:   moderation.Delete,
+		ChatID: chatId,
+		Target: *message.From,
+		Admin:  hebeBot.Self(),
+		Reason: reason,
+	})
+
+	notify := func(text string) {
+		if _, err := hebeBot.Send(tgbotapi.NewMessage(chatId, text)); err != nil {
+			logger.Error("Unable to send filter notice", zap.Error(err))
+		}
+	}
+
+	switch action {
+	case config.FilterWarn:
+		list, err := warnings.Add(warnings.Warning{
+			ChatID:    chatId,
+			UserID:    message.From.ID,
+			AdminID:   hebeBot.Self().ID,
+			AdminName: moderation.UserName(hebeBot.Self()),
+			Reason:    reason,
+			Time:      time.Now(),
+		})
+		if err != nil {
+			logger.Error("Unable to save warning", zap.Error(err))
+			return
+		}
+		moderation.Record(logger, hebeBot, moderation.Action{
+			Kind:   moderation.Warn,
+			ChatID: chatId,
+			Target: *message.From,
+			Admin:  hebeBot.Self(),
+			Reason: reason,
+		})
+		notify(fmt.Sprintf(warnedMessage, moderation.UserName(*message.From), reason))
+
+		if _, escalated, err := warnings.Escalate(logger, hebeBot, chatId, *message.From, len(list)); err != nil {
+			logger.Error("Unable to escalate warnings", zap.Error(err))
+		} else if escalated {
+			logger.Info("Warnings escalated by filter", zap.Int64("chat", chatId), zap.Int64("user", message.From.ID))
+		}
+
+	case config.FilterMute:
+		key := floodKey{chatId: chatId, userId: message.From.ID}
+		if isMuted(key) {
+			return
+		}
+		duration, err := moderation.ParseDuration(cfg.MuteDuration)
+		if err != nil {
+			logger.Error("Invalid filter mute duration", zap.String("duration", cfg.MuteDuration))
+			return
+		}
+		until := time.Now().Add(duration)
+		err = moderation.Apply(logger, hebeBot, moderation.Action{
+			Kind:   moderation.Mute,
+			ChatID: chatId,
+			Target: *message.From,
+			Admin:  hebeBot.Self(),
+			Reason: reason,
+			Until:  until,
+		})
+		if err == nil {
+			mutedMu.Lock()
+			muted[key] = until
+			mutedMu.Unlock()
+			notify(fmt.Sprintf(mutedMessage, moderation.UserName(*message.From), moderation.FormatDuration(duration), reason))
+		}
+	}
+}
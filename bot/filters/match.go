@@ -0,0 +1,214 @@
+package filters
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/moderation"
+	"github.com/hestingames/hg-hebe-bot/config"
+)
+
+// matchLinks matches messages with links to domains not whitelisted.
+func matchLinks(cfg config.FiltersConfig, message *tgbotapi.Message) bool {
+	text, entities := content(message)
+	for _, entity := range entities {
+		var link string
+		switch entity.Type {
+		case "url":
+			link = entityText(text, entity)
+		case "text_link":
+			link = entity.URL
+		default:
+			continue
+		}
+		if !whitelisted(link, cfg.LinkWhitelist) {
+			return true
+		}
+	}
+	return false
+}
+
+// whitelisted reports whether the link goes to one of the whitelisted
+// domains or their subdomains. Entries with a path, like t.me/hestingames,
+// only allow the links under that path.
+func whitelisted(link string, whitelist []string) bool {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	path := strings.ToLower(strings.TrimSuffix(u.Path, "/"))
+
+	for _, entry := range whitelist {
+		entry = strings.ToLower(entry)
+		domain, prefix := entry, ""
+		if i := strings.Index(entry, "/"); i >= 0 {
+			domain, prefix = entry[:i], entry[i:]
+		}
+		if host != domain && !strings.HasSuffix(host, "."+domain) {
+			continue
+		}
+		if prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchForwards matches messages forwarded from channels.
+func matchForwards(cfg config.FiltersConfig, message *tgbotapi.Message) bool {
+	return message.ForwardFromChat != nil && message.ForwardFromChat.IsChannel()
+}
+
+// Compiled banned word patterns
+var (
+	patterns   = make(map[string]*regexp.Regexp)
+	patternsMu sync.Mutex
+)
+
+// matchWords matches messages with any of the banned word patterns.
+func matchWords(cfg config.FiltersConfig, message *tgbotapi.Message) bool {
+	text, _ := content(message)
+	if text == "" {
+		return false
+	}
+	for _, pattern := range cfg.WordPatterns {
+		if re := compile(pattern); re != nil && re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// compile returns the compiled case insensitive pattern, nil if invalid.
+func compile(pattern string) *regexp.Regexp {
+	patternsMu.Lock()
+	defer patternsMu.Unlock()
+	re, ok := patterns[pattern]
+	if !ok {
+		// Invalid patterns are cached too, so they are not compiled again
+		re, _ = regexp.Compile("(?i)" + pattern)
+		patterns[pattern] = re
+	}
+	return re
+}
+
+// matchEmoji matches messages with more than MaxEmoji emoji.
+func matchEmoji(cfg config.FiltersConfig, message *tgbotapi.Message) bool {
+	text, _ := content(message)
+	count := 0
+	for _, r := range text {
+		if isEmoji(r) {
+			count++
+		}
+	}
+	return count > cfg.MaxEmoji
+}
+
+func isEmoji(r rune) bool {
+	return (r >= 0x1F300 && r <= 0x1FAFF) || // Pictographs, emoticons, transport, supplemental symbols
+		(r >= 0x2600 && r <= 0x27BF) || // Miscellaneous symbols and dingbats
+		(r >= 0x1F1E6 && r <= 0x1F1FF) // Regional indicators (flags)
+}
+
+// matchCaps matches messages mostly written in capital letters.
+func matchCaps(cfg config.FiltersConfig, message *tgbotapi.Message) bool {
+	text, _ := content(message)
+	letters, upper := 0, 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	return letters >= cfg.CapsMinLength && letters > 0 &&
+		float64(upper)/float64(letters) > cfg.CapsMaxRatio
+}
+
+// Recent messages of each user on each chat
+var (
+	recent   = make(map[floodKey][]time.Time)
+	recentMu sync.Mutex
+)
+
+type floodKey struct {
+	chatId int64
+	userId int64
+}
+
+// matchFlood matches the messages of users sending more than FloodMessages
+// messages within FloodWindow.
+func matchFlood(cfg config.FiltersConfig, message *tgbotapi.Message) bool {
+	window, err := moderation.ParseDuration(cfg.FloodWindow)
+	if err != nil || cfg.FloodMessages <= 0 {
+		return false
+	}
+
+	now := time.Now()
+	key := floodKey{chatId: message.Chat.ID, userId: message.From.ID}
+
+	recentMu.Lock()
+	defer recentMu.Unlock()
+
+	recent[key] = append(within(recent[key], now, window), now)
+	return len(recent[key]) > cfg.FloodMessages
+}
+
+// sweepFlood forgets the messages out of the window of every user, so the
+// recent messages do not grow forever.
+func sweepFlood(cfg config.FiltersConfig, now time.Time) {
+	// Nothing is matched with an invalid window, everything is forgotten
+	window, _ := moderation.ParseDuration(cfg.FloodWindow)
+
+	recentMu.Lock()
+	defer recentMu.Unlock()
+
+	for key, times := range recent {
+		if kept := within(times, now, window); len(kept) == 0 {
+			delete(recent, key)
+		} else {
+			recent[key] = kept
+		}
+	}
+}
+
+// within drops the times out of the window ending at now.
+func within(times []time.Time, now time.Time, window time.Duration) []time.Time {
+	kept := times[:0]
+	for _, t := range times {
+		if now.Sub(t) < window {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// content returns the text of the message, or the caption of media messages.
+func content(message *tgbotapi.Message) (string, []tgbotapi.MessageEntity) {
+	if message.Text != "" {
+		return message.Text, message.Entities
+	}
+	return message.Caption, message.CaptionEntities
+}
+
+// entityText returns the text of the entity. Entity offsets are in UTF-16
+// code units.
+func entityText(text string, entity tgbotapi.MessageEntity) string {
+	units := utf16.Encode([]rune(text))
+	end := entity.Offset + entity.Length
+	if entity.Offset < 0 || end > len(units) {
+		return ""
+	}
+	return string(utf16.Decode(units[entity.Offset:end]))
+}
@@ -0,0 +1,45 @@
+package filters
+
+import (
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/config"
+)
+
+func TestFlood(t *testing.T) {
+	cfg := config.FiltersConfig{FloodMessages: 2, FloodWindow: "1m"}
+	message := &tgbotapi.Message{
+		Chat: &tgbotapi.Chat{ID: -1},
+		From: &tgbotapi.User{ID: 1},
+	}
+	other := &tgbotapi.Message{
+		Chat: &tgbotapi.Chat{ID: -1},
+		From: &tgbotapi.User{ID: 2},
+	}
+
+	for i, want := range []bool{false, false, true} {
+		if got := matchFlood(cfg, message); got != want {
+			t.Errorf("message %d matched %v, want %v", i+1, got, want)
+		}
+	}
+	if matchFlood(cfg, other) {
+		t.Error("flood of another user matched")
+	}
+
+	// Sweeping keeps the messages within the window
+	sweepFlood(cfg, time.Now())
+	if n := len(recent[floodKey{chatId: -1, userId: 1}]); n != 3 {
+		t.Errorf("%d recent messages kept, want 3", n)
+	}
+
+	// And forgets the users without messages within it
+	sweepFlood(cfg, time.Now().Add(time.Minute))
+	if len(recent) != 0 {
+		t.Errorf("%d users kept after the window, want 0", len(recent))
+	}
+	if matchFlood(cfg, message) {
+		t.Error("message matched after the flood was forgotten")
+	}
+}
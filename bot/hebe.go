@@ -1,29 +1,82 @@
 package hebe
 
 import (
+	"context"
+	"crypto/sha256"
+	"time"
+
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	csgoapi "github.com/hestingames/hg-hebe-bot/api"
+	"github.com/hestingames/hg-hebe-bot/bot/audit"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/callback"
+	"github.com/hestingames/hg-hebe-bot/bot/captcha"
 	"github.com/hestingames/hg-hebe-bot/bot/cmd"
 	"github.com/hestingames/hg-hebe-bot/bot/events"
+	"github.com/hestingames/hg-hebe-bot/bot/federation"
+	"github.com/hestingames/hg-hebe-bot/bot/filters"
+	"github.com/hestingames/hg-hebe-bot/bot/middleware"
+	"github.com/hestingames/hg-hebe-bot/bot/moderation"
+	"github.com/hestingames/hg-hebe-bot/bot/permissions"
+	"github.com/hestingames/hg-hebe-bot/bot/raid"
+	"github.com/hestingames/hg-hebe-bot/bot/reports"
+	"github.com/hestingames/hg-hebe-bot/bot/router"
+	"github.com/hestingames/hg-hebe-bot/bot/sender"
+	"github.com/hestingames/hg-hebe-bot/bot/users"
+	"github.com/hestingames/hg-hebe-bot/bot/warnings"
 	"github.com/hestingames/hg-hebe-bot/config"
 	"github.com/hestingames/hg-hebe-bot/internal/environment"
 	"github.com/hestingames/hg-hebe-bot/internal/logs"
+	"github.com/hestingames/hg-hebe-bot/internal/scheduler"
+	"github.com/hestingames/hg-hebe-bot/internal/store"
+	"github.com/hestingames/hg-hebe-bot/internal/workerpool"
+	"go.uber.org/zap"
 )
 
 const (
-	csgoGroup = 1001456543257
+	// Each user can run the same command up to commandRateLimit times per commandRateWindow
+	commandRateLimit  = 3
+	commandRateWindow = 30 * time.Second
+
+	// How long the cancelled updates have to return once the shutdown
+	// timeout expires
+	cancelTimeout = 5 * time.Second
 )
 
 var (
-	logger *logs.Logger
+	logger   *logs.Logger
+	commands *router.Router
 )
 
-func Initialize(log *logs.Logger) {
+func Initialize(log *logs.Logger, csgo *csgoapi.Client) {
 	logger = log
+	cmd.Initialize(csgo)
+
+	commands = router.New()
+	commands.Use(
+		middleware.Recover(),
+		middleware.Logging(),
+		middleware.Auth(),
+		middleware.ChatFeatures(),
+		middleware.RateLimit(commandRateLimit, commandRateWindow),
+	)
+	commands.UseCallback(
+		middleware.CallbackRecover(),
+		middleware.CallbackLogging(),
+		middleware.CallbackAuth(),
+	)
+	cmd.Register(commands)
+	commands.RegisterCallback(captcha.Callback())
+
+	callback.SetSecret(callbackSecret())
 }
 
-func StartBot() {
+// StartBot receives and handles updates until ctx is cancelled. On shutdown
+// it stops receiving updates, waits for the in-flight ones up to the
+// configured timeout and persists the bot state.
+func StartBot(ctx context.Context) {
 	logger.Info("Initializing bot...")
-	hebeBot, err := tgbotapi.NewBotAPI(config.AppConfig.BotToken)
+	hebeBot, err := tgbotapi.NewBotAPIWithAPIEndpoint(config.AppConfig.BotToken, config.AppConfig.ApiEndpoint)
 	if err != nil {
 		logger.Panic("Unable to initialize telegram bot")
 	}
@@ -32,41 +85,257 @@ func StartBot() {
 		hebeBot.Debug = true
 	}
 
+	Run(ctx, hebeBot)
+}
+
+// Run is StartBot on an already created Bot API client, which may be
+// talking to a fake Bot API server.
+func Run(ctx context.Context, hebeBot *tgbotapi.BotAPI) {
+	var err error
 	logger.Sugar().Infof("Authorized on account: %s", hebeBot.Self.UserName)
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+	// Every request to Telegram made by the handlers goes through the sender
+	outbox := sender.New(botapi.Wrap(hebeBot), logger, sender.Options{
+		GlobalPerSecond: int(config.AppConfig.SendGlobalRate),
+		GroupPerMinute:  int(config.AppConfig.SendGroupRate),
+		MaxRetries:      int(config.AppConfig.SendMaxRetries),
+	})
+
+	state, err := store.Open(config.AppConfig.StatePath)
+	if err != nil {
+		logger.Panic("Unable to open bot state", zap.Error(err))
+	}
+	if err := cmd.LoadState(state); err != nil {
+		logger.Error("Unable to load command state", zap.Error(err))
+	}
+	if err := users.LoadState(state); err != nil {
+		logger.Error("Unable to load seen users", zap.Error(err))
+	}
+	warnings.Initialize(state)
+	if err := audit.Initialize(config.AppConfig.AuditPath, state); err != nil {
+		logger.Panic("Unable to open audit log", zap.Error(err))
+	}
+	federation.Initialize(state)
+	reports.Initialize(state)
+
+	// Timed tasks are persisted, so they survive restarts
+	jobs, err := scheduler.New(state)
+	if err != nil {
+		logger.Panic("Unable to load scheduled jobs", zap.Error(err))
+	}
+	captcha.Initialize(logger, outbox, state, jobs)
+	raid.Initialize(logger, outbox, state, jobs)
+	moderation.Initialize(logger, outbox, jobs)
+	moderation.Reconcile(logger, outbox)
+	jobs.Start()
 
-	updates := hebeBot.GetUpdatesChan(u)
+	// Publish the command lists now and every time the chat settings change
+	publishCommands(outbox, state)
+	config.AppConfig.Chats.Watch(func() {
+		publishCommands(outbox, state)
+	})
 
-	for update := range updates {
-		// Ignore any non-Message updates
-		if update.Message == nil {
-			continue
+	var updates tgbotapi.UpdatesChannel
+	var stopReceiving func()
+	if config.AppConfig.UpdateMode == config.UpdateModeWebhook {
+		if updates, stopReceiving, err = startWebhook(hebeBot); err != nil {
+			logger.Panic("Unable to start webhook", zap.Error(err))
 		}
+	} else {
+		updates, stopReceiving = startPolling(hebeBot)
+	}
 
-		// Ignore message from other chats unless we are in local development environment
-		if update.Message.Chat.ID != csgoGroup && !environment.IsLocal() {
-			events.HandleUnauthorizedMessage(logger, *hebeBot, update)
-			continue
+	// Handlers get their own context so in-flight updates are not cancelled
+	// as soon as the shutdown starts, only when the shutdown timeout expires
+	handlerCtx, cancelHandlers := context.WithCancel(logger.AddToContext(context.Background()))
+	defer cancelHandlers()
+
+	// Updates are handled concurrently, but the ones from the same chat are
+	// always handled by the same worker so they keep their order
+	pool := workerpool.New(int(config.AppConfig.WorkerPoolSize), int(config.AppConfig.WorkerQueueSize))
+	updatesVar.set(pool)
+	stopMetrics := startMetrics()
+	defer stopMetrics()
+	stopSweeping := filters.StartSweeping()
+	defer stopSweeping()
+
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case update, ok := <-updates:
+				if !ok {
+					return
+				}
+				pool.Submit(updateKey(update), func() {
+					// Handlers stop waiting on Telegram once cancelled
+					handleUpdate(handlerCtx, outbox.WithContext(handlerCtx), update)
+				})
+			}
 		}
+	}()
+
+	select {
+	case <-ctx.Done():
+	case <-received:
+		logger.Error("Updates channel closed unexpectedly")
+	}
 
-		if len(update.Message.NewChatMembers) != 0 {
-			events.HandleNewChatMembers(logger, *hebeBot, update)
-			continue
+	logger.Info("Shutting down bot...")
+	stopReceiving()
+	<-received
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pool.Close()
+	}()
+
+	timeout := config.AppConfig.ShutdownTimeout.Get()
+	deadline, cancelDeadline := context.WithTimeout(context.Background(), timeout)
+	defer cancelDeadline()
+	select {
+	case <-done:
+	case <-deadline.Done():
+		logger.Warn("In-flight updates did not finish in time, cancelling them", zap.Duration("timeout", timeout))
+		cancelHandlers()
+		// The queued updates are not even started
+		pool.Stop()
+		select {
+		case <-done:
+		case <-time.After(cancelTimeout):
+			logger.Error("Cancelled updates did not return in time, shutting down anyway", zap.Duration("timeout", cancelTimeout))
 		}
+		logger.Warn("Updates dropped on shutdown", zap.Int64("dropped", pool.Dropped()))
+	}
+
+	jobs.Close()
 
-		// Ignore any non-command Messages
-		if !update.Message.IsCommand() {
-			continue
+	// Flush the messages queued by the handlers
+	if err := outbox.Close(deadline); err != nil {
+		logger.Warn("Some queued messages were not sent", zap.Error(err))
+	}
+
+	if err := cmd.SaveState(state); err != nil {
+		logger.Error("Unable to save command state", zap.Error(err))
+	}
+	if err := users.SaveState(state); err != nil {
+		logger.Error("Unable to save seen users", zap.Error(err))
+	}
+	if err := state.Close(); err != nil {
+		logger.Error("Unable to save bot state", zap.Error(err))
+	}
+	if err := audit.Close(); err != nil {
+		logger.Error("Unable to close audit log", zap.Error(err))
+	}
+	logger.Info("Bot stopped")
+}
+
+// updateKey returns the key used to keep the updates in order, the chat
+// they come from or the user that sent them.
+func updateKey(update tgbotapi.Update) int64 {
+	if update.Message != nil {
+		return update.Message.Chat.ID
+	}
+	if update.CallbackQuery != nil && update.CallbackQuery.Message != nil {
+		return update.CallbackQuery.Message.Chat.ID
+	}
+	if update.ChatMember != nil {
+		return update.ChatMember.Chat.ID
+	}
+	if update.MyChatMember != nil {
+		return update.MyChatMember.Chat.ID
+	}
+	if user := update.SentFrom(); user != nil {
+		return user.ID
+	}
+	return 0
+}
+
+// handleUpdate dispatches an update no matter how it was received.
+func handleUpdate(ctx context.Context, hebeBot botapi.Bot, update tgbotapi.Update) {
+	if update.CallbackQuery != nil {
+		// Buttons of inline messages are not tied to any chat
+		if message := update.CallbackQuery.Message; message == nil || isAuthorized(message.Chat) {
+			commands.DispatchCallback(ctx, logger, hebeBot, update)
 		}
+		return
+	}
+
+	if update.ChatMember != nil || update.MyChatMember != nil {
+		permissions.HandleChatMember(update.ChatMember)
+		permissions.HandleChatMember(update.MyChatMember)
+		captcha.HandleChatMember(hebeBot, update.ChatMember)
+		return
+	}
 
-		// Extract the command from the Message.
-		switch update.Message.Command() {
-		case "rules":
-			cmd.HandleRules(logger, *hebeBot, update)
-		case "csgo":
-			cmd.HandleStatus(logger, *hebeBot, update)
+	if update.InlineQuery != nil {
+		if !inlineAllowed(hebeBot, update.InlineQuery.From) {
+			logger.Debug("Inline query refused by the inline policy", zap.Int64("user", update.InlineQuery.From.ID))
+			return
 		}
+		commands.DispatchInline(ctx, logger, hebeBot, update, router.InlineOptions{
+			CacheTime: int(config.AppConfig.InlineCacheTime.Get()),
+			// Members only results must not be shared with other users
+			IsPersonal: config.AppConfig.InlinePolicy.Get() == config.InlineMembers,
+		})
+		return
+	}
+
+	// Ignore any other non-Message updates
+	if update.Message == nil {
+		return
+	}
+
+	// Ignore message from other chats unless we are in local development environment
+	if !isAuthorized(update.Message.Chat) {
+		events.HandleUnauthorizedMessage(ctx, logger, hebeBot, update)
+		return
+	}
+
+	// Remember the users so commands can target them by @username
+	users.Seen(update)
+
+	// Spam is removed before anything else sees it
+	if filters.Check(ctx, logger, hebeBot, update.Message) {
+		return
+	}
+
+	if len(update.Message.NewChatMembers) != 0 {
+		events.HandleNewChatMembers(ctx, logger, hebeBot, update)
+		return
+	}
+
+	// Non-command and unknown command Messages are ignored by the router
+	commands.Dispatch(ctx, logger, hebeBot, update)
+}
+
+// isAuthorized reports whether the bot is allowed to work in chat. Every chat
+// is allowed in the local development environment.
+func isAuthorized(chat *tgbotapi.Chat) bool {
+	if environment.IsLocal() {
+		return true
+	}
+	if chat.IsPrivate() {
+		return config.AppConfig.AllowPrivate.Get()
+	}
+	// The staff chat gets the reports and their buttons
+	if staff := config.AppConfig.StaffChat.Get(); staff != 0 && chat.ID == staff {
+		return true
+	}
+	_, ok := config.AppConfig.Chat(chat.ID)
+	return ok
+}
+
+// callbackSecret returns the configured callback data signing key, or one
+// derived from the bot token when none is configured.
+func callbackSecret() []byte {
+	if secret := config.AppConfig.CallbackSecret; secret != "" {
+		return []byte(secret)
 	}
+	key := sha256.Sum256([]byte("callback:" + config.AppConfig.BotToken))
+	return key[:]
 }
@@ -0,0 +1,620 @@
+package hebe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	csgoapi "github.com/hestingames/hg-hebe-bot/api"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi/botapitest"
+	"github.com/hestingames/hg-hebe-bot/config"
+	"github.com/hestingames/hg-hebe-bot/internal/environment"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+)
+
+// Chats of the tests, each test uses its own so their requests do not mix
+const (
+	rulesChat        = -1001
+	csgoChat         = -1002
+	welcomeChat      = -1003
+	moderationChat   = -1004
+	raidChat         = -1005
+	reportChat       = -1006
+	modlogChat       = -1007
+	captchaChat      = -1008
+	lockdownChat     = -1009
+	federationChat   = -1010
+	timeoutChat      = -1011
+	unauthorizedChat = -1999
+)
+
+const testConfig = `{
+	"BotToken": "123456:fake-token",
+	"Chats": [
+		{"ID": -1001, "Name": "rules"},
+		{"ID": -1002, "Name": "csgo"},
+		{"ID": -1003, "Name": "welcome", "Welcome": true, "Captcha": "disabled"},
+		{"ID": -1004, "Name": "moderation"},
+		{"ID": -1005, "Name": "raid", "Welcome": true, "Captcha": "button"},
+		{"ID": -1006, "Name": "report"},
+		{"ID": -1007, "Name": "modlog"},
+		{"ID": -1008, "Name": "captcha", "Welcome": true, "Captcha": "arithmetic"},
+		{"ID": -1009, "Name": "lockdown", "Welcome": true, "Captcha": "disabled"},
+		{"ID": -1010, "Name": "federation", "Captcha": "disabled"},
+		{"ID": -1011, "Name": "timeout", "Captcha": "button"}
+	],
+	"Owners": [501],
+	"CaptchaTimeout": "2s",
+	"RaidJoins": 3,
+	"UnauthorizedAction": "reply"
+}`
+
+const (
+	csgoServers = `{"servers":[
+		{"serverId":1,"map":"de_dust2","gameType":519,"playersId":[1,2,3],"matchmakingServer":true},
+		{"serverId":2,"map":"cs_office","gameType":135200775,"playersId":[4],"matchmakingServer":true}]}`
+	csgoQueues = `[
+		{"gameType":519,"currentPlaying":3,"currentSearching":2,"serverList":[1]},
+		{"gameType":135200775,"currentPlaying":1,"currentSearching":0,"serverList":[2]}]`
+)
+
+// Fake Bot API the bot under test talks to
+var server *botapitest.Server
+
+// TestMain runs the bot once against the fake Bot API and a fake CSGO api
+// for all the tests.
+func TestMain(m *testing.M) {
+	// Every chat is authorized in the local environment, the tests run in
+	// dev to check the allow-list
+	environment.Set("dev")
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	dir, err := os.MkdirTemp("", "hebe-test")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The config is read from the working directory
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(testConfig), 0600); err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	config.LoadConfig(func(key string, err error, msg string) {})
+	defer config.Close()
+	config.AppConfig.StatePath = filepath.Join(dir, "state.json")
+	config.AppConfig.AuditPath = filepath.Join(dir, "audit.jsonl")
+
+	csgoBackend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/query/servers":
+			w.Write([]byte(csgoServers))
+		case "/query/queues":
+			w.Write([]byte(csgoQueues))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer csgoBackend.Close()
+	csgo, err := csgoapi.NewClient(csgoapi.WithBaseURL(csgoBackend.URL))
+	if err != nil {
+		panic(err)
+	}
+
+	logger, err := logs.New()
+	if err != nil {
+		panic(err)
+	}
+	Initialize(logger, csgo)
+
+	server = botapitest.NewServer()
+	defer server.Close()
+	server.Handle("getChatAdministrators", func(params url.Values) (interface{}, error) {
+		switch params.Get("chat_id") {
+		case strconv.Itoa(modlogChat):
+			return nil, &tgbotapi.Error{Code: http.StatusBadRequest, Message: "Bad Request: chat not found"}
+		case strconv.Itoa(moderationChat), strconv.Itoa(raidChat), strconv.Itoa(reportChat), strconv.Itoa(lockdownChat):
+			return []tgbotapi.ChatMember{
+				{User: &moderator, Status: "administrator", CanRestrictMembers: true},
+				{User: &helper, Status: "administrator"},
+			}, nil
+		}
+		return []tgbotapi.ChatMember{}, nil
+	})
+	// Files are downloaded from the fake too
+	config.AppConfig.ApiEndpoint = server.Endpoint()
+	bot, err := server.Bot()
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		Run(ctx, bot)
+	}()
+
+	code := m.Run()
+	cancel()
+	<-stopped
+	return code
+}
+
+// waitMessage waits for a message containing text sent to the chat.
+func waitMessage(t *testing.T, chatId int64, text string) botapitest.Request {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, request := range server.Requests("sendMessage") {
+			if request.ChatID() == chatId && strings.Contains(request.Params.Get("text"), text) {
+				return request
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("no message containing %q sent to chat %d, sent: %v", text, chatId, sentTo(chatId))
+	return botapitest.Request{}
+}
+
+// sentTo returns the texts of the messages sent to the chat.
+func sentTo(chatId int64) []string {
+	var texts []string
+	for _, request := range server.Requests("sendMessage") {
+		if request.ChatID() == chatId {
+			texts = append(texts, request.Params.Get("text"))
+		}
+	}
+	return texts
+}
+
+// Admins of moderationChat, raidChat, reportChat and lockdownChat, only the
+// moderator can restrict members
+var (
+	moderator = tgbotapi.User{ID: 201, FirstName: "Moderator"}
+	helper    = tgbotapi.User{ID: 202, FirstName: "Helper"}
+)
+
+// waitRequest waits for a request to method on the chat about the user.
+func waitRequest(t *testing.T, method string, chatId, userId int64, timeout time.Duration) botapitest.Request {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		for _, request := range server.Requests(method) {
+			if request.ChatID() == chatId && request.Params.Get("user_id") == strconv.FormatInt(userId, 10) {
+				return request
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("no %s request for user %d in chat %d", method, userId, chatId)
+	return botapitest.Request{}
+}
+
+// waitReply waits for a message containing text sent in reply to the
+// message of the update.
+func waitReply(t *testing.T, update tgbotapi.Update, text string) {
+	t.Helper()
+	replyTo := strconv.Itoa(update.Message.MessageID)
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, request := range server.Requests("sendMessage") {
+			if request.ChatID() == update.Message.Chat.ID && request.Params.Get("reply_to_message_id") == replyTo &&
+				strings.Contains(request.Params.Get("text"), text) {
+				return
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("no reply containing %q to message %s, sent: %v", text, replyTo, sentTo(update.Message.Chat.ID))
+}
+
+// buttons returns the callback data of the buttons of the message by their
+// text.
+func buttons(t *testing.T, request botapitest.Request) map[string]string {
+	t.Helper()
+	var markup tgbotapi.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(request.Params.Get("reply_markup")), &markup); err != nil {
+		t.Fatal(err)
+	}
+	data := make(map[string]string)
+	for _, row := range markup.InlineKeyboard {
+		for _, button := range row {
+			if button.CallbackData != nil {
+				data[button.Text] = *button.CallbackData
+			}
+		}
+	}
+	return data
+}
+
+// press presses a button of a message in the chat and returns the answer
+// to the callback query.
+func press(t *testing.T, id string, from tgbotapi.User, chat tgbotapi.Chat, data string) string {
+	t.Helper()
+	server.InjectUpdate(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      id,
+		From:    &from,
+		Message: &tgbotapi.Message{MessageID: 1, Chat: &chat},
+		Data:    data,
+	}})
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, request := range server.Requests("answerCallbackQuery") {
+			if request.Params.Get("callback_query_id") == id {
+				return request.Params.Get("text")
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("callback query %s not answered", id)
+	return ""
+}
+
+func group(id int64) tgbotapi.Chat {
+	return tgbotapi.Chat{ID: id, Type: "supergroup", Title: "Test"}
+}
+
+func TestRules(t *testing.T) {
+	user := tgbotapi.User{ID: 101, FirstName: "Rules"}
+	update := server.InjectMessage(group(rulesChat), user, "/rules")
+
+	request := waitMessage(t, rulesChat, "Normas del Grupo")
+	if got := request.Params.Get("reply_to_message_id"); got != strconv.Itoa(update.Message.MessageID) {
+		t.Errorf("rules reply to message %s, want %d", got, update.Message.MessageID)
+	}
+}
+
+func TestCsgoStatus(t *testing.T) {
+	user := tgbotapi.User{ID: 102, FirstName: "Gamer"}
+	server.InjectMessage(group(csgoChat), user, "/csgo")
+
+	request := waitMessage(t, csgoChat, "Playing Now: 4")
+	text := request.Params.Get("text")
+	for _, want := range []string{"Dust II: 3", "Hostages : 1"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("status missing %q:\n%s", want, text)
+		}
+	}
+	// Servers and queues agree
+	if strings.Contains(text, "no coinciden") {
+		t.Errorf("status reports inconsistent data:\n%s", text)
+	}
+	if request.Params.Get("reply_markup") == "" {
+		t.Error("status sent without the refresh button")
+	}
+}
+
+func TestWelcome(t *testing.T) {
+	member := tgbotapi.User{ID: 103, FirstName: "Nuevo"}
+	server.InjectNewMembers(group(welcomeChat), member)
+
+	waitMessage(t, welcomeChat, "Bienvenid@")
+}
+
+func TestWelcomeSkipsBots(t *testing.T) {
+	bot := tgbotapi.User{ID: 104, FirstName: "Spam", IsBot: true}
+	server.InjectNewMembers(group(welcomeChat), bot)
+	// Followed by a real member, so the bot join has been handled once the
+	// member is welcomed
+	member := tgbotapi.User{ID: 105, FirstName: "Persona"}
+	server.InjectNewMembers(group(welcomeChat), member)
+
+	waitMessage(t, welcomeChat, "Persona")
+	for _, text := range sentTo(welcomeChat) {
+		if strings.Contains(text, "Spam") {
+			t.Errorf("bot welcomed: %q", text)
+		}
+	}
+}
+
+func TestUnauthorizedChat(t *testing.T) {
+	user := tgbotapi.User{ID: 106, FirstName: "Intruso"}
+	server.InjectMessage(group(unauthorizedChat), user, "/rules")
+
+	waitMessage(t, unauthorizedChat, "Chat no autorizado")
+	time.Sleep(100 * time.Millisecond)
+	for _, text := range sentTo(unauthorizedChat) {
+		if strings.Contains(text, "Normas del Grupo") {
+			t.Error("command handled in an unauthorized chat")
+		}
+	}
+}
+
+func TestWarnNeedsRestrictRight(t *testing.T) {
+	member := tgbotapi.User{ID: 203, FirstName: "Member"}
+	server.InjectMessage(group(moderationChat), member, "hola")
+
+	// Warnings escalate to mutes and bans, admins that cannot restrict
+	// members cannot warn
+	server.InjectMessage(group(moderationChat), helper, "/warn 203 spam")
+	waitMessage(t, moderationChat, "no tiene permiso para restringir")
+
+	server.InjectMessage(group(moderationChat), moderator, "/warn 203 spam")
+	waitMessage(t, moderationChat, "ha recibido una advertencia (1/5)")
+
+	warned := 0
+	for _, text := range sentTo(moderationChat) {
+		if strings.Contains(text, "ha recibido una advertencia") {
+			warned++
+		}
+	}
+	if warned != 1 {
+		t.Errorf("%d warnings announced, want 1", warned)
+	}
+}
+
+func TestRaidHoldsNewMembers(t *testing.T) {
+	raiders := []tgbotapi.User{
+		{ID: 301, FirstName: "Raider1"},
+		{ID: 302, FirstName: "Raider2"},
+		{ID: 303, FirstName: "Raider3"},
+	}
+	server.InjectNewMembers(group(raidChat), raiders...)
+	waitMessage(t, raidChat, "el grupo queda bloqueado")
+
+	// Joining a locked chat does not skip the captcha
+	late := tgbotapi.User{ID: 304, FirstName: "Raider4"}
+	server.InjectNewMembers(group(raidChat), late)
+	held := map[string]bool{}
+	deadline := time.Now().Add(3 * time.Second)
+	for len(held) < 4 && time.Now().Before(deadline) {
+		for _, request := range server.Requests("restrictChatMember") {
+			if request.ChatID() == raidChat {
+				held[request.Params.Get("user_id")] = true
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if len(held) != 4 {
+		t.Fatalf("%d members restricted during the lockdown, want 4", len(held))
+	}
+	for _, text := range sentTo(raidChat) {
+		if strings.Contains(text, "Raider") {
+			t.Errorf("message posted during the lockdown: %q", text)
+		}
+	}
+
+	// They are challenged once the lockdown is lifted
+	server.InjectMessage(group(raidChat), moderator, "/unlock")
+	waitMessage(t, raidChat, "bloqueo del grupo ha sido levantado")
+	for _, raider := range append(raiders, late) {
+		waitMessage(t, raidChat, "Hola "+raider.FirstName)
+	}
+}
+
+func TestReportRestoredOnFailure(t *testing.T) {
+	// The first mute in the chat fails
+	var restricts int32
+	server.Handle("restrictChatMember", func(params url.Values) (interface{}, error) {
+		if params.Get("chat_id") == strconv.Itoa(reportChat) && atomic.AddInt32(&restricts, 1) == 1 {
+			return nil, &tgbotapi.Error{Code: http.StatusBadRequest, Message: "Bad Request: not enough rights"}
+		}
+		return true, nil
+	})
+	defer server.Handle("restrictChatMember", func(url.Values) (interface{}, error) { return true, nil })
+
+	// Reports are sent privately to the admins that started the bot
+	private := tgbotapi.Chat{ID: moderator.ID, Type: "private"}
+	server.InjectMessage(private, moderator, "/start")
+	spammer := tgbotapi.User{ID: 401, FirstName: "Spammer"}
+	reporter := tgbotapi.User{ID: 402, FirstName: "Reporter"}
+	spam := server.InjectMessage(group(reportChat), spammer, "spam")
+	server.InjectReply(group(reportChat), reporter, "/report", spam.Message)
+
+	notice := waitMessage(t, moderator.ID, "Reporte en")
+	data := buttons(t, notice)["🔇 Silenciar"]
+
+	// The failed mute leaves the report pending
+	if text := press(t, "report-1", moderator, private, data); !strings.Contains(text, "No se pudo completar") {
+		t.Fatalf("failed mute answered %q", text)
+	}
+	if text := press(t, "report-2", moderator, private, data); !strings.Contains(text, "Hecho") {
+		t.Fatalf("retried mute answered %q", text)
+	}
+	waitMessage(t, reportChat, "Spammer")
+}
+
+func TestModlogSkipsUncheckedChats(t *testing.T) {
+	// The administrators of modlogChat can never be checked, the log of the
+	// chats the moderator administrates is still shown
+	private := tgbotapi.Chat{ID: moderator.ID, Type: "private"}
+	server.InjectMessage(private, moderator, "/modlog 999999")
+	waitMessage(t, moderator.ID, "No hay acciones de moderación registradas")
+}
+
+func TestReportCooldownAndDuplicates(t *testing.T) {
+	spammer := tgbotapi.User{ID: 411, FirstName: "Spammer"}
+	reporter := tgbotapi.User{ID: 412, FirstName: "Reporter"}
+	other := tgbotapi.User{ID: 413, FirstName: "Other"}
+	first := server.InjectMessage(group(reportChat), spammer, "spam")
+	second := server.InjectMessage(group(reportChat), spammer, "more spam")
+
+	// Reports are sent privately to the admins that started the bot
+	server.InjectMessage(tgbotapi.Chat{ID: moderator.ID, Type: "private"}, moderator, "/start")
+	report := server.InjectReply(group(reportChat), reporter, "/report", first.Message)
+	waitReply(t, report, "los administradores han sido notificados")
+
+	// Reporting again right away is refused, even another message
+	again := server.InjectReply(group(reportChat), reporter, "/report", second.Message)
+	waitReply(t, again, "Espere un poco antes de volver a reportar")
+
+	// Each message is only reported once
+	duplicated := server.InjectReply(group(reportChat), other, "/report", first.Message)
+	waitReply(t, duplicated, "Ese mensaje ya ha sido reportado")
+}
+
+func TestCaptchaPassed(t *testing.T) {
+	member := tgbotapi.User{ID: 701, FirstName: "Humano"}
+	server.InjectNewMembers(group(captchaChat), member)
+	waitRequest(t, "restrictChatMember", captchaChat, member.ID, 3*time.Second)
+	right, _ := captchaAnswers(t, waitMessage(t, captchaChat, "Hola Humano"))
+
+	// Somebody else can not solve it
+	intruder := tgbotapi.User{ID: 702, FirstName: "Intruso"}
+	if answer := press(t, "captcha-intruder", intruder, group(captchaChat), right); !strings.Contains(answer, "no es para usted") {
+		t.Errorf("captcha of another user answered %q", answer)
+	}
+	if answer := press(t, "captcha-pass", member, group(captchaChat), right); !strings.Contains(answer, "Verificación completada") {
+		t.Fatalf("right answer answered %q", answer)
+	}
+	waitMessage(t, captchaChat, "Bienvenid@")
+
+	// Solved captchas do not time out
+	time.Sleep(2500 * time.Millisecond)
+	for _, request := range server.Requests("banChatMember") {
+		if request.Params.Get("user_id") == strconv.FormatInt(member.ID, 10) {
+			t.Error("member kicked after passing the captcha")
+		}
+	}
+}
+
+func TestCaptchaFailed(t *testing.T) {
+	member := tgbotapi.User{ID: 703, FirstName: "Robot"}
+	server.InjectNewMembers(group(captchaChat), member)
+	_, wrong := captchaAnswers(t, waitMessage(t, captchaChat, "Hola Robot"))
+	if answer := press(t, "captcha-fail", member, group(captchaChat), wrong); !strings.Contains(answer, "Respuesta incorrecta") {
+		t.Fatalf("wrong answer answered %q", answer)
+	}
+	waitRequest(t, "banChatMember", captchaChat, member.ID, 3*time.Second)
+	waitRequest(t, "unbanChatMember", captchaChat, member.ID, 3*time.Second)
+
+	// The captcha is gone
+	if answer := press(t, "captcha-again", member, group(captchaChat), wrong); !strings.Contains(answer, "caducado") {
+		t.Errorf("failed captcha answered again %q", answer)
+	}
+}
+
+func TestCaptchaTimeout(t *testing.T) {
+	member := tgbotapi.User{ID: 704, FirstName: "Ausente"}
+	server.InjectNewMembers(group(timeoutChat), member)
+	waitMessage(t, timeoutChat, "Hola Ausente")
+
+	// Kicked once CaptchaTimeout expires
+	waitRequest(t, "banChatMember", timeoutChat, member.ID, 5*time.Second)
+	waitRequest(t, "unbanChatMember", timeoutChat, member.ID, time.Second)
+}
+
+func TestRaidThreshold(t *testing.T) {
+	first := tgbotapi.User{ID: 311, FirstName: "Primero"}
+	server.InjectNewMembers(group(lockdownChat), first)
+	waitMessage(t, lockdownChat, "Primero")
+	if locked := server.Requests("setChatPermissions"); len(filterChat(locked, lockdownChat)) != 0 {
+		t.Fatal("chat locked below the threshold")
+	}
+
+	// The third join within RaidWindow locks the chat down
+	server.InjectNewMembers(group(lockdownChat), tgbotapi.User{ID: 312, FirstName: "Segundo"}, tgbotapi.User{ID: 313, FirstName: "Tercero"})
+	waitMessage(t, lockdownChat, "Se han unido 3 usuarios")
+	locks := filterChat(server.Requests("setChatPermissions"), lockdownChat)
+	if len(locks) != 1 {
+		t.Fatalf("%d permission changes, want 1", len(locks))
+	}
+	var permissions tgbotapi.ChatPermissions
+	if err := json.Unmarshal([]byte(locks[0].Params.Get("permissions")), &permissions); err != nil {
+		t.Fatal(err)
+	}
+	if permissions.CanSendMessages {
+		t.Error("members can still write during the lockdown")
+	}
+	for _, text := range sentTo(lockdownChat) {
+		if strings.Contains(text, "Segundo") || strings.Contains(text, "Tercero") {
+			t.Errorf("member welcomed during the lockdown: %q", text)
+		}
+	}
+
+	// The lockdown is only lifted once
+	server.InjectMessage(group(lockdownChat), moderator, "/unlock")
+	waitMessage(t, lockdownChat, "bloqueo del grupo ha sido levantado")
+	if lifts := filterChat(server.Requests("setChatPermissions"), lockdownChat); len(lifts) != 2 {
+		t.Errorf("%d permission changes, want 2", len(lifts))
+	}
+	server.InjectMessage(group(lockdownChat), moderator, "/unlock")
+	waitMessage(t, lockdownChat, "El grupo no está bloqueado")
+
+	// The joins are counted again from zero
+	server.InjectNewMembers(group(lockdownChat), tgbotapi.User{ID: 314, FirstName: "Cuarto"})
+	waitMessage(t, lockdownChat, "Cuarto")
+}
+
+func TestFederationImport(t *testing.T) {
+	owner := tgbotapi.User{ID: 501, FirstName: "Owner"}
+	private := tgbotapi.Chat{ID: owner.ID, Type: "private"}
+	importBans := func(fileId, doc string) tgbotapi.Update {
+		server.AddFile(fileId, []byte(doc))
+		document := &tgbotapi.Message{MessageID: 1, Chat: &private, Document: &tgbotapi.Document{FileID: fileId}}
+		return server.InjectReply(private, owner, "/fbanimport", document)
+	}
+
+	// Duplicated and invalid bans are skipped
+	const bans = `{"Version": 1, "Bans": [{"UserID": 601, "Reason": "spam"}, {"UserID": 602}, {"UserID": 601}, {"UserID": 0}]}`
+	waitReply(t, importBans("fbans-1", bans), "Se han importado 2 baneos nuevos")
+	// The bans already in the list are kept
+	waitReply(t, importBans("fbans-2", bans), "Se han importado 0 baneos nuevos")
+	waitReply(t, importBans("fbans-3", `{"Version": 2, "Bans": [{"UserID": 603}]}`), "unsupported ban list version 2")
+
+	// Imported users are banned when they join
+	server.InjectNewMembers(group(federationChat), tgbotapi.User{ID: 601, FirstName: "Baneado"})
+	waitRequest(t, "banChatMember", federationChat, 601, 3*time.Second)
+	server.InjectNewMembers(group(federationChat), tgbotapi.User{ID: 603, FirstName: "Nuevo"})
+	time.Sleep(200 * time.Millisecond)
+	for _, request := range filterChat(server.Requests("banChatMember"), federationChat) {
+		if request.Params.Get("user_id") == "603" {
+			t.Error("user of a rejected ban list banned")
+		}
+	}
+}
+
+// captchaAnswers returns the callback data of the right choice of an
+// arithmetic captcha and of a wrong one.
+func captchaAnswers(t *testing.T, challenge botapitest.Request) (right, wrong string) {
+	t.Helper()
+	text := challenge.Params.Get("text")
+	var a, b int
+	if _, err := fmt.Sscanf(text[strings.Index(text, "¿"):], "¿Cuánto es %d + %d?", &a, &b); err != nil {
+		t.Fatalf("no sum in %q: %v", text, err)
+	}
+	choices := buttons(t, challenge)
+	if len(choices) != 4 {
+		t.Errorf("%d choices, want 4", len(choices))
+	}
+	for choice, data := range choices {
+		if choice == strconv.Itoa(a+b) {
+			right = data
+		} else {
+			wrong = data
+		}
+	}
+	if right == "" {
+		t.Fatalf("no choice is %d + %d", a, b)
+	}
+	return right, wrong
+}
+
+// filterChat returns the requests to the chat.
+func filterChat(requests []botapitest.Request, chatId int64) []botapitest.Request {
+	var filtered []botapitest.Request
+	for _, request := range requests {
+		if request.ChatID() == chatId {
+			filtered = append(filtered, request)
+		}
+	}
+	return filtered
+}
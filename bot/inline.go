@@ -0,0 +1,70 @@
+package hebe
+
+import (
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/config"
+	"github.com/hestingames/hg-hebe-bot/internal/environment"
+	"go.uber.org/zap"
+)
+
+// How long the membership of a user to the allowed chats is cached
+const inlineMembershipTTL = 10 * time.Minute
+
+type membership struct {
+	member  bool
+	checked time.Time
+}
+
+var (
+	inlineMembers   = make(map[int64]membership)
+	inlineMembersMu sync.Mutex
+)
+
+// inlineAllowed reports whether user can use the inline mode according to
+// the configured inline policy.
+func inlineAllowed(hebeBot botapi.Bot, user *tgbotapi.User) bool {
+	if environment.IsLocal() {
+		return true
+	}
+
+	switch config.AppConfig.InlinePolicy.Get() {
+	case config.InlineEveryone:
+		return true
+	case config.InlineMembers:
+		return user != nil && isMember(hebeBot, user.ID)
+	}
+	return false
+}
+
+// isMember reports whether the user is a member of any of the allowed chats.
+func isMember(hebeBot botapi.Bot, userId int64) bool {
+	inlineMembersMu.Lock()
+	cached, ok := inlineMembers[userId]
+	inlineMembersMu.Unlock()
+	if ok && time.Since(cached.checked) < inlineMembershipTTL {
+		return cached.member
+	}
+
+	member := false
+	for _, chat := range config.AppConfig.Chats.Get().([]config.ChatConfig) {
+		chatMember, err := botapi.GetChatMember(hebeBot, chat.ID, userId)
+		if err != nil {
+			logger.Debug("Unable to get chat member", zap.Int64("chat", chat.ID), zap.Error(err))
+			continue
+		}
+		if !chatMember.HasLeft() && !chatMember.WasKicked() {
+			member = true
+			break
+		}
+	}
+
+	inlineMembersMu.Lock()
+	inlineMembers[userId] = membership{member: member, checked: time.Now()}
+	inlineMembersMu.Unlock()
+
+	return member
+}
@@ -0,0 +1,71 @@
+package hebe
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hestingames/hg-hebe-bot/config"
+	"github.com/hestingames/hg-hebe-bot/internal/workerpool"
+	"go.uber.org/zap"
+)
+
+// Metrics of the updates pool of the running bot. Published once, as expvar
+// does not allow publishing a name twice, and pointed to the pool of every Run
+var updatesVar = &poolVar{}
+
+func init() {
+	expvar.Publish("updates", updatesVar)
+}
+
+// poolVar forwards to the metrics of the current pool
+type poolVar struct {
+	mu   sync.Mutex
+	pool *workerpool.Pool
+}
+
+func (v *poolVar) set(pool *workerpool.Pool) {
+	v.mu.Lock()
+	v.pool = pool
+	v.mu.Unlock()
+}
+
+func (v *poolVar) String() string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.pool == nil {
+		return "null"
+	}
+	return v.pool.Var().String()
+}
+
+// startMetrics serves the expvar metrics (/debug/vars) when a metrics
+// address is configured. The returned function stops the listener.
+func startMetrics() func() {
+	if config.AppConfig.MetricsListen == "" {
+		return func() {}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	server := &http.Server{
+		Addr:              config.AppConfig.MetricsListen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("Metrics listener stopped", zap.Error(err))
+		}
+	}()
+	logger.Info("Serving metrics", zap.String("listen", server.Addr))
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"context"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/callback"
+	"github.com/hestingames/hg-hebe-bot/bot/permissions"
+	"github.com/hestingames/hg-hebe-bot/bot/router"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+)
+
+const (
+	adminOnlyMessage = "🙅🏻‍♀️ Lo siento, esta acción solo está disponible para los administradores del grupo"
+	ownerOnlyMessage = "🙅🏻‍♀️ Lo siento, esta acción solo está disponible para los responsables del bot"
+)
+
+// Auth enforces the scope declared by each command: commands are ignored in
+// chats they are not meant for, and admin and owner commands are politely
+// refused to everyone else.
+func Auth() router.Middleware {
+	return func(command *router.Command, next router.HandlerFunc) router.HandlerFunc {
+		return func(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
+			if !command.Scope.AllowsChat(update.Message.Chat) {
+				return
+			}
+
+			if refusal := guard(logger, hebeBot, command.Scope, update.Message.Chat, update.Message.From); refusal != "" {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, refusal)
+				msg.ReplyToMessageID = update.Message.MessageID
+				if _, err := hebeBot.Send(msg); err != nil {
+					logger.Sugar().Errorf("Unable to send the refusal message :%s", err)
+				}
+				return
+			}
+
+			next(ctx, logger, hebeBot, update)
+		}
+	}
+}
+
+// CallbackAuth enforces the scope declared by each callback. Buttons of
+// inline messages are not tied to a chat, only ScopeAll callbacks accept them.
+func CallbackAuth() router.CallbackMiddleware {
+	return func(cb *router.Callback, next router.CallbackFunc) router.CallbackFunc {
+		return func(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, query *tgbotapi.CallbackQuery, data callback.Data) router.Answer {
+			if query.Message == nil {
+				if cb.Scope != router.ScopeAll {
+					return router.Answer{}
+				}
+				return next(ctx, logger, hebeBot, query, data)
+			}
+
+			if !cb.Scope.AllowsChat(query.Message.Chat) {
+				return router.Answer{}
+			}
+
+			if refusal := guard(logger, hebeBot, cb.Scope, query.Message.Chat, query.From); refusal != "" {
+				return router.Answer{Text: refusal, Alert: true}
+			}
+
+			return next(ctx, logger, hebeBot, query, data)
+		}
+	}
+}
+
+// guard checks the user against the admin and owner bits of scope and
+// returns the refusal message when the user is not allowed.
+func guard(logger *logs.Logger, hebeBot botapi.Bot, scope router.Scope, chat *tgbotapi.Chat, user *tgbotapi.User) string {
+	if scope.Has(router.ScopeOwner) && (user == nil || !permissions.IsOwner(user.ID)) {
+		return ownerOnlyMessage
+	}
+	if scope.Has(router.ScopeAdmin) && !isAdmin(logger, hebeBot, chat, user) {
+		return adminOnlyMessage
+	}
+	return ""
+}
+
+func isAdmin(logger *logs.Logger, hebeBot botapi.Bot, chat *tgbotapi.Chat, user *tgbotapi.User) bool {
+	if user == nil {
+		return false
+	}
+
+	// In private chats the user is the only member
+	if chat.IsPrivate() {
+		return true
+	}
+
+	admin, err := permissions.IsAdmin(hebeBot, chat.ID, user.ID)
+	if err != nil {
+		logger.Sugar().Errorf("Unable to get chat administrators :%s", err)
+		return false
+	}
+	return admin
+}
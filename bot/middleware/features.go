@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"context"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/router"
+	"github.com/hestingames/hg-hebe-bot/config"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+)
+
+// ChatFeatures ignores the commands that are disabled in the chat settings.
+func ChatFeatures() router.Middleware {
+	return func(command *router.Command, next router.HandlerFunc) router.HandlerFunc {
+		return func(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
+			chat, _ := config.AppConfig.Chat(update.Message.Chat.ID)
+			if !chat.CommandEnabled(command.Name) {
+				return
+			}
+			next(ctx, logger, hebeBot, update)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/callback"
+	"github.com/hestingames/hg-hebe-bot/bot/router"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+	"go.uber.org/zap"
+)
+
+// Logging logs every dispatched command and how long it took to handle.
+func Logging() router.Middleware {
+	return func(command *router.Command, next router.HandlerFunc) router.HandlerFunc {
+		return func(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
+			start := time.Now()
+			next(ctx, logger, hebeBot, update)
+
+			logger.Info("Command handled",
+				zap.String("command", command.Name),
+				zap.Int64("chat", update.Message.Chat.ID),
+				zap.Int64("user", userID(update)),
+				zap.Duration("elapsed", time.Since(start)))
+		}
+	}
+}
+
+// CallbackLogging logs every dispatched callback and how long it took to handle.
+func CallbackLogging() router.CallbackMiddleware {
+	return func(cb *router.Callback, next router.CallbackFunc) router.CallbackFunc {
+		return func(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, query *tgbotapi.CallbackQuery, data callback.Data) router.Answer {
+			start := time.Now()
+			answer := next(ctx, logger, hebeBot, query, data)
+
+			logger.Info("Callback handled",
+				zap.String("namespace", cb.Namespace),
+				zap.Strings("args", data.Args),
+				zap.Int64("user", query.From.ID),
+				zap.Duration("elapsed", time.Since(start)))
+			return answer
+		}
+	}
+}
+
+func userID(update tgbotapi.Update) int64 {
+	if update.Message.From == nil {
+		return 0
+	}
+	return update.Message.From.ID
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/router"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+	"go.uber.org/zap"
+)
+
+type rateKey struct {
+	command string
+	chat    int64
+	user    int64
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// RateLimit allows each user to run the same command at most limit times
+// per window in a chat. Exceeding invocations are dropped silently, so the
+// limit can't be used to make the bot spam refusals.
+func RateLimit(limit int, window time.Duration) router.Middleware {
+	var mu sync.Mutex
+	windows := make(map[rateKey]*rateWindow)
+
+	allow := func(key rateKey) bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		now := time.Now()
+		w, ok := windows[key]
+		if !ok || now.Sub(w.start) >= window {
+			// Drop expired windows so the map doesn't grow forever
+			for k, v := range windows {
+				if now.Sub(v.start) >= window {
+					delete(windows, k)
+				}
+			}
+			windows[key] = &rateWindow{start: now, count: 1}
+			return true
+		}
+
+		w.count++
+		return w.count <= limit
+	}
+
+	return func(command *router.Command, next router.HandlerFunc) router.HandlerFunc {
+		return func(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
+			key := rateKey{command: command.Name, chat: update.Message.Chat.ID, user: userID(update)}
+			if !allow(key) {
+				logger.Debug("Command rate limited",
+					zap.String("command", command.Name),
+					zap.Int64("chat", key.chat),
+					zap.Int64("user", key.user))
+				return
+			}
+			next(ctx, logger, hebeBot, update)
+		}
+	}
+}
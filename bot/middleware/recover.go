@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/callback"
+	"github.com/hestingames/hg-hebe-bot/bot/router"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+	"go.uber.org/zap"
+)
+
+// Recover stops a panicking handler from taking the whole bot down.
+func Recover() router.Middleware {
+	return func(command *router.Command, next router.HandlerFunc) router.HandlerFunc {
+		return func(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Error("Command handler panicked",
+						zap.String("command", command.Name),
+						zap.Any("panic", r),
+						zap.Stack("stack"))
+				}
+			}()
+			next(ctx, logger, hebeBot, update)
+		}
+	}
+}
+
+// CallbackRecover stops a panicking callback handler from taking the whole bot down.
+func CallbackRecover() router.CallbackMiddleware {
+	return func(cb *router.Callback, next router.CallbackFunc) router.CallbackFunc {
+		return func(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, query *tgbotapi.CallbackQuery, data callback.Data) (answer router.Answer) {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Error("Callback handler panicked",
+						zap.String("namespace", cb.Namespace),
+						zap.Any("panic", r),
+						zap.Stack("stack"))
+				}
+			}()
+			return next(ctx, logger, hebeBot, query, data)
+		}
+	}
+}
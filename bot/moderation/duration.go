@@ -0,0 +1,80 @@
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errInvalidDuration = errors.New("invalid duration")
+
+// Duration units accepted by ParseDuration
+var durationUnits = map[byte]time.Duration{
+	's': time.Second,
+	'm': time.Minute,
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+// ParseDuration parses the durations given to the moderation commands, a
+// sequence of numbers followed by a unit (s, m, h, d, w) like "2h" or "1d12h".
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.ToLower(s)
+	if s == "" {
+		return 0, errInvalidDuration
+	}
+
+	var total time.Duration
+	for s != "" {
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 || i > 6 || i == len(s) {
+			return 0, errInvalidDuration
+		}
+		unit, ok := durationUnits[s[i]]
+		if !ok {
+			return 0, errInvalidDuration
+		}
+		n, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return 0, errInvalidDuration
+		}
+		// Longer than time.Duration can hold, about 292 years
+		if int64(n) > math.MaxInt64/int64(unit) || time.Duration(n)*unit > math.MaxInt64-total {
+			return 0, errInvalidDuration
+		}
+		total += time.Duration(n) * unit
+		s = s[i+1:]
+	}
+	return total, nil
+}
+
+// FormatDuration formats a duration with the units of ParseDuration.
+func FormatDuration(d time.Duration) string {
+	var b strings.Builder
+	for _, unit := range []struct {
+		suffix string
+		length time.Duration
+	}{
+		{"w", 7 * 24 * time.Hour},
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+		{"m", time.Minute},
+		{"s", time.Second},
+	} {
+		if n := d / unit.length; n > 0 {
+			fmt.Fprintf(&b, "%d%s", n, unit.suffix)
+			d -= n * unit.length
+		}
+	}
+	if b.Len() == 0 {
+		return "0s"
+	}
+	return b.String()
+}
@@ -0,0 +1,51 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"30s", 30 * time.Second, true},
+		{"2h", 2 * time.Hour, true},
+		{"1d12h", 36 * time.Hour, true},
+		{"1W", 7 * 24 * time.Hour, true},
+		{"", 0, false},
+		{"h", 0, false},
+		{"12", 0, false},
+		{"3y", 0, false},
+		{"1234567s", 0, false},
+		{"106751d", 106751 * 24 * time.Hour, true},
+		// Longer than time.Duration can hold
+		{"999999d", 0, false},
+		{"999999w", 0, false},
+		{"106751d999999h", 0, false},
+	}
+	for _, test := range tests {
+		got, err := ParseDuration(test.in)
+		if (err == nil) != test.ok || got != test.want {
+			t.Errorf("ParseDuration(%q) = %s, %v, want %s, ok %v", test.in, got, err, test.want, test.ok)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                           "0s",
+		90 * time.Second:            "1m30s",
+		36 * time.Hour:              "1d12h",
+		8 * 24 * time.Hour:          "1w1d",
+		1500 * time.Millisecond:     "1s",
+		2*time.Hour + 5*time.Second: "2h5s",
+	}
+	for d, want := range tests {
+		if got := FormatDuration(d); got != want {
+			t.Errorf("FormatDuration(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package moderation
+
+import (
+	"fmt"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/config"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+	"github.com/hestingames/hg-hebe-bot/internal/scheduler"
+	"go.uber.org/zap"
+)
+
+const (
+	expiryJobKind      = "moderation.expiry"
+	expiryJobPrefix    = "moderation.expiry."
+	expiryReason       = "Fin de la restricción temporal"
+	unmuteAnnouncement = "🔊 %s puede volver a escribir"
+)
+
+// expiry is a temporary ban or mute to be lifted
+type expiry struct {
+	Kind   Kind
+	ChatID int64
+	Target tgbotapi.User
+	Until  time.Time
+}
+
+var jobs *scheduler.Scheduler
+
+// Initialize lifts the temporary bans and mutes when they expire, with jobs
+// so they are also lifted across restarts.
+func Initialize(logger *logs.Logger, hebeBot botapi.Bot, sched *scheduler.Scheduler) {
+	jobs = sched
+	jobs.Handle(expiryJobKind, func(job scheduler.Job) error {
+		var e expiry
+		if err := job.Decode(&e); err != nil {
+			logger.Error("Invalid expiry job", zap.String("job", job.ID), zap.Error(err))
+			return nil
+		}
+		// Retried until lifted, as the bot restricts without expiry
+		return lift(logger, hebeBot, e)
+	})
+}
+
+// Reconcile checks the pending expiries against the current state of the
+// chat members, and drops the ones of restrictions lifted by hand out of the
+// bot. Expiries due while the bot was stopped are lifted once the scheduler
+// starts.
+func Reconcile(logger *logs.Logger, hebeBot botapi.Bot) {
+	pending := jobs.Jobs(expiryJobKind)
+	dropped := 0
+	for _, job := range pending {
+		var e expiry
+		if err := job.Decode(&e); err != nil {
+			logger.Error("Invalid expiry job, dropping it", zap.String("job", job.ID), zap.Error(err))
+			jobs.Cancel(job.ID)
+			dropped++
+			continue
+		}
+		if !job.At.After(time.Now()) {
+			continue
+		}
+
+		member, err := botapi.GetChatMember(hebeBot, e.ChatID, e.Target.ID)
+		if err != nil {
+			// Keep it, lifting an expired restriction is harmless
+			logger.Warn("Unable to check restricted member", zap.String("job", job.ID), zap.Error(err))
+			continue
+		}
+		if (e.Kind == Mute && member.Status != "restricted") || (e.Kind == Ban && !member.WasKicked()) {
+			jobs.Cancel(job.ID)
+			dropped++
+		}
+	}
+	logger.Info("Temporary restrictions reconciled", zap.Int("pending", len(pending)-dropped), zap.Int("dropped", dropped))
+}
+
+// trackExpiry schedules the lift of temporary bans and mutes, and drops the
+// pending lifts of restrictions replaced or lifted by the action.
+func trackExpiry(logger *logs.Logger, action Action) {
+	if jobs == nil {
+		return
+	}
+
+	var err error
+	switch action.Kind {
+	case Ban:
+		// A ban replaces any mute
+		cancelExpiry(logger, action.ChatID, action.Target.ID, Mute)
+		err = scheduleExpiry(action)
+	case Mute:
+		err = scheduleExpiry(action)
+	case Unmute:
+		cancelExpiry(logger, action.ChatID, action.Target.ID, Mute)
+	case Unban:
+		cancelExpiry(logger, action.ChatID, action.Target.ID, Ban)
+	}
+	if err != nil {
+		logger.Error("Unable to schedule restriction expiry", append(fields(action), zap.Error(err))...)
+	}
+}
+
+// scheduleExpiry schedules the lift of the action, permanent actions drop
+// any previous expiry.
+func scheduleExpiry(action Action) error {
+	id := expiryJobId(action.ChatID, action.Target.ID, action.Kind)
+	if action.Until.IsZero() {
+		return jobs.Cancel(id)
+	}
+	job, err := scheduler.NewJob(id, expiryJobKind, action.Until, expiry{
+		Kind:   action.Kind,
+		ChatID: action.ChatID,
+		Target: action.Target,
+		Until:  action.Until,
+	})
+	if err != nil {
+		return err
+	}
+	return jobs.Schedule(job)
+}
+
+func cancelExpiry(logger *logs.Logger, chatId, userId int64, kind Kind) {
+	if err := jobs.Cancel(expiryJobId(chatId, userId, kind)); err != nil {
+		logger.Error("Unable to cancel restriction expiry", zap.Int64("chat", chatId), zap.Int64("user", userId), zap.Error(err))
+	}
+}
+
+// lift lifts an expired ban or mute.
+func lift(logger *logs.Logger, hebeBot botapi.Bot, e expiry) error {
+	kind := Unmute
+	if e.Kind == Ban {
+		kind = Unban
+	}
+	err := Apply(logger, hebeBot, Action{
+		Kind:   kind,
+		ChatID: e.ChatID,
+		Target: e.Target,
+		Admin:  hebeBot.Self(),
+		Reason: expiryReason,
+	})
+	if err != nil {
+		return err
+	}
+	if kind != Unmute || !config.AppConfig.AnnounceUnmutes.Get() {
+		return nil
+	}
+	if _, err := hebeBot.Send(tgbotapi.NewMessage(e.ChatID, fmt.Sprintf(unmuteAnnouncement, UserName(e.Target)))); err != nil {
+		logger.Error("Unable to announce unmute", zap.Error(err))
+	}
+	return nil
+}
+
+func expiryJobId(chatId, userId int64, kind Kind) string {
+	return fmt.Sprintf("%s%d.%d.%s", expiryJobPrefix, chatId, userId, kind)
+}
@@ -0,0 +1,171 @@
+// Package moderation applies the moderation actions on the chat members.
+// Every action goes through Apply or Record, so they are all logged and
+// audited the same way no matter if an admin or an automatic rule took them.
+package moderation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/audit"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+	"go.uber.org/zap"
+)
+
+// Kind of moderation action
+type Kind string
+
+const (
+	Ban    Kind = "ban"
+	Kick   Kind = "kick"
+	Mute   Kind = "mute"
+	Unmute Kind = "unmute"
+	Unban  Kind = "unban"
+
+	// Actions recorded by Record, they are taken elsewhere
+	Warn       Kind = "warn"
+	ResetWarns Kind = "resetwarns"
+	Delete     Kind = "delete"
+	Lockdown   Kind = "lockdown"
+	Unlock     Kind = "unlock"
+)
+
+// Action is a moderation action on a chat member.
+type Action struct {
+	Kind   Kind
+	ChatID int64
+	Target tgbotapi.User // Zero for actions on the whole chat
+	Admin  tgbotapi.User // Acting admin, the bot itself for automatic actions
+	Reason string
+	Until  time.Time // Zero for permanent bans and mutes
+}
+
+// Telegram treats restrictions shorter than 30 seconds or longer than 366
+// days as permanent, those are lifted by the expiry jobs only
+const (
+	minDuration = 30 * time.Second
+	maxDuration = 366 * 24 * time.Hour
+)
+
+// Apply takes the action on Telegram, logs it along with the acting admin
+// and adds it to the audit log.
+func Apply(logger *logs.Logger, hebeBot botapi.Bot, action Action) error {
+	member := tgbotapi.ChatMemberConfig{ChatID: action.ChatID, UserID: action.Target.ID}
+
+	var err error
+	switch action.Kind {
+	case Ban:
+		_, err = hebeBot.Request(tgbotapi.BanChatMemberConfig{
+			ChatMemberConfig: member,
+			UntilDate:        untilDate(action.Until),
+		})
+	case Kick:
+		// Kicking is banning and unbanning right away, so the user can join again
+		if _, err = hebeBot.Request(tgbotapi.BanChatMemberConfig{ChatMemberConfig: member}); err == nil {
+			_, err = hebeBot.Request(tgbotapi.UnbanChatMemberConfig{ChatMemberConfig: member, OnlyIfBanned: true})
+		}
+	case Mute:
+		_, err = hebeBot.Request(tgbotapi.RestrictChatMemberConfig{
+			ChatMemberConfig: member,
+			UntilDate:        untilDate(action.Until),
+			Permissions:      &tgbotapi.ChatPermissions{},
+		})
+	case Unmute:
+		_, err = hebeBot.Request(tgbotapi.RestrictChatMemberConfig{
+			ChatMemberConfig: member,
+			Permissions:      DefaultPermissions(hebeBot, action.ChatID),
+		})
+	case Unban:
+		_, err = hebeBot.Request(tgbotapi.UnbanChatMemberConfig{ChatMemberConfig: member, OnlyIfBanned: true})
+	default:
+		err = fmt.Errorf("unknown moderation action %q", action.Kind)
+	}
+
+	if err != nil {
+		logger.Error("Moderation action failed", append(fields(action), zap.Error(err))...)
+		return err
+	}
+	Record(logger, hebeBot, action)
+	trackExpiry(logger, action)
+	return nil
+}
+
+// Record logs an action taken without Apply and adds it to the audit log.
+func Record(logger *logs.Logger, hebeBot botapi.Bot, action Action) {
+	logger.Info("Moderation action applied", fields(action)...)
+
+	entry := audit.Entry{
+		ChatID:    action.ChatID,
+		Action:    string(action.Kind),
+		ActorID:   action.Admin.ID,
+		ActorName: UserName(action.Admin),
+		Reason:    action.Reason,
+		Until:     action.Until,
+	}
+	if action.Target.ID != 0 {
+		entry.TargetID = action.Target.ID
+		entry.TargetName = UserName(action.Target)
+	}
+	audit.Record(logger, hebeBot, entry)
+}
+
+func fields(action Action) []zap.Field {
+	fields := []zap.Field{
+		zap.String("action", string(action.Kind)),
+		zap.Int64("chat", action.ChatID),
+		zap.Int64("target", action.Target.ID),
+		zap.String("targetName", action.Target.String()),
+		zap.Int64("admin", action.Admin.ID),
+		zap.String("adminName", action.Admin.String()),
+		zap.String("reason", action.Reason),
+	}
+	if !action.Until.IsZero() {
+		fields = append(fields, zap.Time("until", action.Until))
+	}
+	return fields
+}
+
+// untilDate returns the until_date sent to Telegram. Restrictions Telegram
+// would take as permanent are sent as permanent, the expiry job lifts them.
+func untilDate(until time.Time) int64 {
+	if until.IsZero() {
+		return 0
+	}
+	if duration := time.Until(until); duration < minDuration || duration > maxDuration {
+		return 0
+	}
+	return until.Unix()
+}
+
+// DefaultPermissions returns the permissions of the members of the chat, so
+// unrestricted users get the same permissions as everybody else.
+func DefaultPermissions(hebeBot botapi.Bot, chatId int64) *tgbotapi.ChatPermissions {
+	if chat, err := botapi.GetChat(hebeBot, chatId); err == nil && chat.Permissions != nil {
+		return chat.Permissions
+	}
+	return &tgbotapi.ChatPermissions{
+		CanSendMessages:       true,
+		CanSendMediaMessages:  true,
+		CanSendPolls:          true,
+		CanSendOtherMessages:  true,
+		CanAddWebPagePreviews: true,
+		CanInviteUsers:        true,
+	}
+}
+
+// UserName returns the name of the user as shown in the bot messages.
+func UserName(user tgbotapi.User) string {
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	switch {
+	case name != "" && user.UserName != "":
+		return fmt.Sprintf("%s (@%s)", name, user.UserName)
+	case name != "":
+		return name
+	case user.UserName != "":
+		return "@" + user.UserName
+	}
+	return fmt.Sprintf("%d", user.ID)
+}
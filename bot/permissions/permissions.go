@@ -0,0 +1,116 @@
+// Package permissions tells chat administrators and bot owners apart from
+// regular members. The administrators of each chat are cached, the cache is
+// refreshed after AdminCacheTTL and whenever Telegram reports a change on the
+// chat members.
+package permissions
+
+import (
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/config"
+)
+
+type chatAdmins struct {
+	members map[int64]tgbotapi.ChatMember
+	fetched time.Time
+}
+
+var (
+	admins   = make(map[int64]chatAdmins)
+	adminsMu sync.Mutex
+)
+
+// IsOwner reports whether the user is one of the configured bot owners.
+func IsOwner(userId int64) bool {
+	for _, owner := range config.AppConfig.Owners.Get().([]int64) {
+		if owner == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin reports whether the user is an administrator of the chat. Bot
+// owners are administrators of every chat.
+func IsAdmin(hebeBot botapi.Bot, chatId, userId int64) (bool, error) {
+	if IsOwner(userId) {
+		return true, nil
+	}
+	_, ok, err := Admin(hebeBot, chatId, userId)
+	return ok, err
+}
+
+// Admin returns the administrator membership of the user in the chat, so
+// the rights of the administrator can be checked.
+func Admin(hebeBot botapi.Bot, chatId, userId int64) (tgbotapi.ChatMember, bool, error) {
+	members, err := chatAdministrators(hebeBot, chatId)
+	if err != nil {
+		return tgbotapi.ChatMember{}, false, err
+	}
+	member, ok := members[userId]
+	return member, ok, nil
+}
+
+// Admins returns the administrators of the chat, bots included.
+func Admins(hebeBot botapi.Bot, chatId int64) ([]tgbotapi.ChatMember, error) {
+	members, err := chatAdministrators(hebeBot, chatId)
+	if err != nil {
+		return nil, err
+	}
+	admins := make([]tgbotapi.ChatMember, 0, len(members))
+	for _, member := range members {
+		admins = append(admins, member)
+	}
+	return admins, nil
+}
+
+// Invalidate drops the cached administrators of the chat.
+func Invalidate(chatId int64) {
+	adminsMu.Lock()
+	delete(admins, chatId)
+	adminsMu.Unlock()
+}
+
+// HandleChatMember keeps the cache up to date with the chat_member and
+// my_chat_member updates. Only promotions and demotions invalidate the cache.
+func HandleChatMember(updated *tgbotapi.ChatMemberUpdated) {
+	if updated == nil {
+		return
+	}
+	if isAdminMember(updated.OldChatMember) || isAdminMember(updated.NewChatMember) {
+		Invalidate(updated.Chat.ID)
+	}
+}
+
+func chatAdministrators(hebeBot botapi.Bot, chatId int64) (map[int64]tgbotapi.ChatMember, error) {
+	adminsMu.Lock()
+	cached, ok := admins[chatId]
+	adminsMu.Unlock()
+	if ok && time.Since(cached.fetched) < config.AppConfig.AdminCacheTTL.Get() {
+		return cached.members, nil
+	}
+
+	list, err := botapi.GetChatAdministrators(hebeBot, chatId)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make(map[int64]tgbotapi.ChatMember, len(list))
+	for _, member := range list {
+		if member.User != nil {
+			members[member.User.ID] = member
+		}
+	}
+
+	adminsMu.Lock()
+	admins[chatId] = chatAdmins{members: members, fetched: time.Now()}
+	adminsMu.Unlock()
+	return members, nil
+}
+
+func isAdminMember(member tgbotapi.ChatMember) bool {
+	return member.IsCreator() || member.IsAdministrator()
+}
@@ -0,0 +1,171 @@
+package hebe
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/router"
+	"github.com/hestingames/hg-hebe-bot/config"
+	"github.com/hestingames/hg-hebe-bot/internal/store"
+	"go.uber.org/zap"
+)
+
+// Store key of the last published command lists
+const publishedCommandsKey = "hebe.publishedCommands"
+
+// Languages the command lists are published in, "" is the default language (Spanish)
+var commandLanguages = []string{"", "en"}
+
+// commandList is a command list published for a scope and language.
+type commandList struct {
+	Scope    tgbotapi.BotCommandScope
+	Language string
+	Commands []tgbotapi.BotCommand
+}
+
+// publishedCommands is what was published last time, so the lists are only
+// published again when they change.
+type publishedCommands struct {
+	Fingerprint string
+	Chats       []int64 // Chats with their own command lists
+}
+
+var publishMu sync.Mutex
+
+// publishCommands publishes the command lists shown by Telegram clients:
+// group members, group administrators and private chats get their own
+// lists, and so do the chats with only some commands enabled.
+func publishCommands(hebeBot botapi.Bot, st *store.Store) {
+	publishMu.Lock()
+	defer publishMu.Unlock()
+
+	chats := config.AppConfig.Chats.Get().([]config.ChatConfig)
+	lists, chatIds := commandLists(commands.Commands(), chats)
+
+	raw, err := json.Marshal(lists)
+	if err != nil {
+		logger.Error("Unable to encode command lists", zap.Error(err))
+		return
+	}
+	sum := sha256.Sum256(raw)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	var published publishedCommands
+	if _, err := st.Get(publishedCommandsKey, &published); err != nil {
+		logger.Error("Unable to load published commands", zap.Error(err))
+	}
+	if published.Fingerprint == fingerprint {
+		return
+	}
+
+	logger.Info("Publishing command lists", zap.Int("lists", len(lists)))
+	for _, list := range lists {
+		var request tgbotapi.Chattable
+		if len(list.Commands) == 0 {
+			request = tgbotapi.NewDeleteMyCommandsWithScopeAndLanguage(list.Scope, list.Language)
+		} else {
+			request = tgbotapi.NewSetMyCommandsWithScopeAndLanguage(list.Scope, list.Language, list.Commands...)
+		}
+		if _, err := hebeBot.Request(request); err != nil {
+			logger.Error("Unable to publish command list",
+				zap.String("scope", list.Scope.Type),
+				zap.Int64("chat", list.Scope.ChatID),
+				zap.String("language", list.Language),
+				zap.Error(err))
+			// Try again on the next publish
+			return
+		}
+	}
+
+	// Chats that no longer have their own lists go back to the general ones
+	for _, chatId := range published.Chats {
+		if containsChat(chatIds, chatId) {
+			continue
+		}
+		for _, language := range commandLanguages {
+			for _, scope := range []tgbotapi.BotCommandScope{
+				tgbotapi.NewBotCommandScopeChat(chatId),
+				tgbotapi.NewBotCommandScopeChatAdministrators(chatId),
+			} {
+				if _, err := hebeBot.Request(tgbotapi.NewDeleteMyCommandsWithScopeAndLanguage(scope, language)); err != nil {
+					logger.Warn("Unable to delete chat command list", zap.Int64("chat", chatId), zap.Error(err))
+				}
+			}
+		}
+	}
+
+	published = publishedCommands{Fingerprint: fingerprint, Chats: chatIds}
+	if err := st.Put(publishedCommandsKey, published); err != nil {
+		logger.Error("Unable to save published commands", zap.Error(err))
+	}
+}
+
+// commandLists builds the command lists of every scope and language. It also
+// returns the chats that need their own lists.
+func commandLists(commands []router.Command, chats []config.ChatConfig) ([]commandList, []int64) {
+	// Owner commands are not listed, owners know them already
+	all := func(c router.Command) bool { return !c.Scope.Has(router.ScopeOwner) }
+	members := func(c router.Command) bool { return all(c) && !c.Scope.Has(router.ScopeAdmin) }
+
+	group := func(chat config.ChatConfig, filter func(router.Command) bool) func(router.Command) bool {
+		return func(c router.Command) bool {
+			return c.Scope.Has(router.ScopeGroup) && chat.CommandEnabled(c.Name) && filter(c)
+		}
+	}
+	// Private chats can not be told apart, only commands for everyone are listed
+	private := func(c router.Command) bool { return members(c) && c.Scope.Has(router.ScopePrivate) }
+
+	var lists []commandList
+	var chatIds []int64
+	for _, language := range commandLanguages {
+		lists = append(lists,
+			newCommandList(tgbotapi.NewBotCommandScopeAllGroupChats(), language, commands, group(config.ChatConfig{}, members)),
+			newCommandList(tgbotapi.NewBotCommandScopeAllChatAdministrators(), language, commands, group(config.ChatConfig{}, all)),
+			newCommandList(tgbotapi.NewBotCommandScopeAllPrivateChats(), language, commands, private),
+		)
+
+		for _, chat := range chats {
+			if len(chat.Commands) == 0 {
+				continue
+			}
+			lists = append(lists,
+				newCommandList(tgbotapi.NewBotCommandScopeChat(chat.ID), language, commands, group(chat, members)),
+				newCommandList(tgbotapi.NewBotCommandScopeChatAdministrators(chat.ID), language, commands, group(chat, all)),
+			)
+		}
+	}
+
+	for _, chat := range chats {
+		if len(chat.Commands) != 0 {
+			chatIds = append(chatIds, chat.ID)
+		}
+	}
+
+	return lists, chatIds
+}
+
+func newCommandList(scope tgbotapi.BotCommandScope, language string, commands []router.Command, filter func(router.Command) bool) commandList {
+	list := commandList{Scope: scope, Language: language, Commands: []tgbotapi.BotCommand{}}
+	for _, command := range commands {
+		if filter(command) {
+			list.Commands = append(list.Commands, tgbotapi.BotCommand{
+				Command:     command.Name,
+				Description: command.DescriptionIn(language),
+			})
+		}
+	}
+	return list
+}
+
+func containsChat(chatIds []int64, chatId int64) bool {
+	for _, id := range chatIds {
+		if id == chatId {
+			return true
+		}
+	}
+	return false
+}
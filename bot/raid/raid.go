@@ -0,0 +1,298 @@
+// Package raid detects waves of accounts joining a chat at once and locks
+// the chat down until the wave is over. During a lockdown members can not
+// write and new members are not welcomed. The ones that must solve a captcha
+// are held restricted, without posting it, and challenged once it is lifted.
+package raid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/captcha"
+	"github.com/hestingames/hg-hebe-bot/bot/moderation"
+	"github.com/hestingames/hg-hebe-bot/bot/permissions"
+	"github.com/hestingames/hg-hebe-bot/config"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+	"github.com/hestingames/hg-hebe-bot/internal/scheduler"
+	"github.com/hestingames/hg-hebe-bot/internal/store"
+	"go.uber.org/zap"
+)
+
+const (
+	liftJobKind       = "raid.lift"
+	lockdownKeyPrefix = "raid."
+)
+
+const (
+	lockedMessage      = "🚨 Se han unido %d usuarios en %s, el grupo queda bloqueado temporalmente.\nEl bloqueo se levantará tras %s sin nuevas entradas o con /unlock"
+	adminNoticeMessage = "🚨 Posible raid en %s: se han unido %d usuarios en %s. El grupo ha sido bloqueado, use /unlock en el grupo para levantar el bloqueo."
+	liftedMessage      = "✅ El bloqueo del grupo ha sido levantado"
+)
+
+// ErrNotLocked is returned when lifting the lockdown of a chat not locked down.
+var ErrNotLocked = errors.New("chat not locked down")
+
+// lockdown of a chat, persisted so it can be lifted after a restart
+type lockdown struct {
+	ChatID      int64
+	Since       time.Time
+	Permissions *tgbotapi.ChatPermissions // Permissions before the lockdown
+	Held        []tgbotapi.User           // Members to challenge once lifted
+}
+
+var (
+	logger *logs.Logger
+	state  *store.Store
+	jobs   *scheduler.Scheduler
+
+	// Recent joins of each chat
+	joins   = make(map[int64][]time.Time)
+	joinsMu sync.Mutex
+
+	// Serializes locking and lifting the lockdowns
+	lockMu sync.Mutex
+)
+
+// Initialize sets where the lockdowns are persisted and lifts them with
+// jobs, so they are also lifted across restarts.
+func Initialize(log *logs.Logger, hebeBot botapi.Bot, st *store.Store, sched *scheduler.Scheduler) {
+	logger = log
+	state = st
+	jobs = sched
+
+	jobs.Handle(liftJobKind, func(job scheduler.Job) error {
+		var chatId int64
+		if err := job.Decode(&chatId); err != nil {
+			logger.Error("Invalid raid lift job", zap.String("job", job.ID), zap.Error(err))
+			return nil
+		}
+		err := Lift(hebeBot, chatId, hebeBot.Self())
+		if err == ErrNotLocked {
+			return nil
+		}
+		return err
+	})
+}
+
+// Joined records members joining the chat and locks it down when too many
+// joined within the raid window. It reports whether the chat is locked down.
+func Joined(hebeBot botapi.Bot, chat *tgbotapi.Chat, count int) bool {
+	threshold := int(config.AppConfig.RaidJoins.Get())
+	if threshold <= 0 {
+		return false
+	}
+	window := config.AppConfig.RaidWindow.Get()
+
+	now := time.Now()
+	joinsMu.Lock()
+	recent := joins[chat.ID][:0]
+	for _, t := range joins[chat.ID] {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	for i := 0; i < count; i++ {
+		recent = append(recent, now)
+	}
+	joins[chat.ID] = recent
+	total := len(recent)
+	joinsMu.Unlock()
+
+	lockMu.Lock()
+	defer lockMu.Unlock()
+
+	if Locked(chat.ID) {
+		// Every join keeps the chat locked for another quiet period
+		if err := scheduleLift(chat.ID); err != nil {
+			logger.Error("Unable to schedule lockdown lift", zap.Int64("chat", chat.ID), zap.Error(err))
+		}
+		return true
+	}
+	if total < threshold {
+		return false
+	}
+
+	logger.Warn("Raid detected, locking chat down", zap.Int64("chat", chat.ID), zap.Int("joins", total), zap.Duration("window", window))
+	if err := lock(hebeBot, chat.ID); err != nil {
+		logger.Error("Unable to lock chat down", zap.Int64("chat", chat.ID), zap.Error(err))
+		return false
+	}
+	moderation.Record(logger, hebeBot, moderation.Action{
+		Kind:   moderation.Lockdown,
+		ChatID: chat.ID,
+		Admin:  hebeBot.Self(),
+		Reason: fmt.Sprintf("%d entradas en %s", total, moderation.FormatDuration(window)),
+	})
+	notify(hebeBot, chat, total, window)
+	return true
+}
+
+// Locked reports whether the chat is locked down.
+func Locked(chatId int64) bool {
+	ok, err := state.Get(lockdownKey(chatId), &lockdown{})
+	return ok && err == nil
+}
+
+// Hold restricts a member that joined during the lockdown without posting
+// anything, the captcha is posted once the lockdown is lifted.
+func Hold(hebeBot botapi.Bot, chatId int64, user tgbotapi.User) error {
+	lockMu.Lock()
+	defer lockMu.Unlock()
+
+	var l lockdown
+	key := lockdownKey(chatId)
+	if ok, err := state.Get(key, &l); err != nil {
+		return err
+	} else if !ok {
+		return ErrNotLocked
+	}
+
+	if _, err := hebeBot.Request(tgbotapi.RestrictChatMemberConfig{
+		ChatMemberConfig: tgbotapi.ChatMemberConfig{ChatID: chatId, UserID: user.ID},
+		Permissions:      &tgbotapi.ChatPermissions{},
+	}); err != nil {
+		return err
+	}
+	l.Held = append(l.Held, user)
+	return state.Put(key, l)
+}
+
+// Lift restores the permissions the chat had before the lockdown. The admin
+// lifting it is recorded, the bot itself when lifted automatically.
+func Lift(hebeBot botapi.Bot, chatId int64, admin tgbotapi.User) error {
+	l, err := unlock(hebeBot, chatId)
+	if err != nil {
+		return err
+	}
+
+	moderation.Record(logger, hebeBot, moderation.Action{
+		Kind:   moderation.Unlock,
+		ChatID: chatId,
+		Admin:  admin,
+		Reason: fmt.Sprintf("Bloqueo de %s", moderation.FormatDuration(time.Since(l.Since).Round(time.Second))),
+	})
+	if _, err := hebeBot.Send(tgbotapi.NewMessage(chatId, liftedMessage)); err != nil {
+		logger.Error("Unable to announce lockdown lift", zap.Error(err))
+	}
+	release(hebeBot, chatId, l.Held)
+	return nil
+}
+
+// unlock restores the permissions of the chat and forgets its lockdown,
+// which is returned so the members held can be released without lockMu.
+func unlock(hebeBot botapi.Bot, chatId int64) (lockdown, error) {
+	lockMu.Lock()
+	defer lockMu.Unlock()
+
+	var l lockdown
+	key := lockdownKey(chatId)
+	if ok, err := state.Get(key, &l); err != nil {
+		return l, err
+	} else if !ok {
+		return l, ErrNotLocked
+	}
+
+	if _, err := hebeBot.Request(tgbotapi.SetChatPermissionsConfig{
+		ChatConfig:  tgbotapi.ChatConfig{ChatID: chatId},
+		Permissions: l.Permissions,
+	}); err != nil {
+		return l, err
+	}
+	if err := state.Delete(key); err != nil {
+		return l, err
+	}
+	if err := jobs.Cancel(key); err != nil {
+		logger.Error("Unable to cancel lockdown lift", zap.Int64("chat", chatId), zap.Error(err))
+	}
+
+	joinsMu.Lock()
+	delete(joins, chatId)
+	joinsMu.Unlock()
+	return l, nil
+}
+
+// release challenges the members held during the lockdown that are still in
+// the chat.
+func release(hebeBot botapi.Bot, chatId int64, held []tgbotapi.User) {
+	for _, user := range held {
+		member, err := botapi.GetChatMember(hebeBot, chatId, user.ID)
+		if err != nil {
+			logger.Error("Unable to get held member", zap.Int64("chat", chatId), zap.Int64("user", user.ID), zap.Error(err))
+			continue
+		}
+		if member.HasLeft() || member.WasKicked() {
+			continue
+		}
+		if err := captcha.Challenge(context.Background(), hebeBot, chatId, user); err != nil {
+			logger.Error("Unable to challenge held member", zap.Int64("chat", chatId), zap.Int64("user", user.ID), zap.Error(err))
+		}
+	}
+}
+
+// lock saves the permissions of the chat and takes them all away. Must be
+// called with lockMu held.
+func lock(hebeBot botapi.Bot, chatId int64) error {
+	l := lockdown{
+		ChatID:      chatId,
+		Since:       time.Now(),
+		Permissions: moderation.DefaultPermissions(hebeBot, chatId),
+	}
+	if _, err := hebeBot.Request(tgbotapi.SetChatPermissionsConfig{
+		ChatConfig:  tgbotapi.ChatConfig{ChatID: chatId},
+		Permissions: &tgbotapi.ChatPermissions{},
+	}); err != nil {
+		return err
+	}
+	if err := state.Put(lockdownKey(chatId), l); err != nil {
+		return err
+	}
+	return scheduleLift(chatId)
+}
+
+func scheduleLift(chatId int64) error {
+	at := time.Now().Add(config.AppConfig.RaidQuietPeriod.Get())
+	job, err := scheduler.NewJob(lockdownKey(chatId), liftJobKind, at, chatId)
+	if err != nil {
+		return err
+	}
+	return jobs.Schedule(job)
+}
+
+// notify tells the chat and its admins about the lockdown. Admins that
+// never started a private chat with the bot can not be told privately.
+func notify(hebeBot botapi.Bot, chat *tgbotapi.Chat, joins int, window time.Duration) {
+	quiet := config.AppConfig.RaidQuietPeriod.Get()
+	text := fmt.Sprintf(lockedMessage, joins, moderation.FormatDuration(window), moderation.FormatDuration(quiet))
+	if _, err := hebeBot.Send(tgbotapi.NewMessage(chat.ID, text)); err != nil {
+		logger.Error("Unable to announce lockdown", zap.Error(err))
+	}
+
+	admins, err := permissions.Admins(hebeBot, chat.ID)
+	if err != nil {
+		logger.Error("Unable to get chat administrators", zap.Error(err))
+		return
+	}
+	title := chat.Title
+	if title == "" {
+		title = strconv.FormatInt(chat.ID, 10)
+	}
+	notice := fmt.Sprintf(adminNoticeMessage, title, joins, moderation.FormatDuration(window))
+	for _, admin := range admins {
+		if admin.User == nil || admin.User.IsBot {
+			continue
+		}
+		if _, err := hebeBot.Send(tgbotapi.NewMessage(admin.User.ID, notice)); err != nil {
+			logger.Debug("Unable to notify admin", zap.Int64("admin", admin.User.ID), zap.Error(err))
+		}
+	}
+}
+
+func lockdownKey(chatId int64) string {
+	return fmt.Sprintf("%s%d", lockdownKeyPrefix, chatId)
+}
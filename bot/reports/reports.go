@@ -0,0 +1,175 @@
+// Package reports keeps the reports members make about other members'
+// messages until an admin handles them.
+package reports
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/config"
+	"github.com/hestingames/hg-hebe-bot/internal/store"
+)
+
+// Prefix of the store keys of the reports, followed by the report id
+const reportKeyPrefix = "reports."
+
+// Reports older than reportTTL are dropped, their message may not even
+// exist anymore
+const reportTTL = 7 * 24 * time.Hour
+
+var (
+	// ErrCooldown is returned when a user reports again too soon.
+	ErrCooldown = errors.New("report cooldown")
+	// ErrDuplicated is returned when the message was already reported.
+	ErrDuplicated = errors.New("message already reported")
+	// ErrNotFound is returned for reports already handled or expired.
+	ErrNotFound = errors.New("report not found")
+
+	errNotInitialized = errors.New("reports not initialized")
+)
+
+// Notice is a message telling the admins about a report
+type Notice struct {
+	ChatID    int64
+	ChatTitle string
+	MessageID int
+}
+
+// Report of a message
+type Report struct {
+	ID        string
+	ChatID    int64
+	ChatTitle string
+	MessageID int
+	Target    tgbotapi.User
+	Reporter  tgbotapi.User
+	Reason    string
+	Time      time.Time
+	Notices   []Notice
+}
+
+var (
+	reports   *store.Store
+	reportsMu sync.Mutex
+
+	// Last report of each user
+	lastReports = make(map[int64]time.Time)
+)
+
+// Initialize sets the store the reports are persisted in.
+func Initialize(st *store.Store) {
+	reportsMu.Lock()
+	reports = st
+	reportsMu.Unlock()
+}
+
+// Create saves a new report. Users can only report once per ReportCooldown
+// and each message is only reported once.
+func Create(report Report) (Report, error) {
+	reportsMu.Lock()
+	defer reportsMu.Unlock()
+	if reports == nil {
+		return report, errNotInitialized
+	}
+
+	now := time.Now()
+	cooldown := config.AppConfig.ReportCooldown.Get()
+	if last, ok := lastReports[report.Reporter.ID]; ok && now.Sub(last) < cooldown {
+		return report, ErrCooldown
+	}
+	for userId, last := range lastReports {
+		if now.Sub(last) >= cooldown {
+			delete(lastReports, userId)
+		}
+	}
+
+	for _, key := range reports.Keys(reportKeyPrefix) {
+		var existing Report
+		if _, err := reports.Get(key, &existing); err != nil {
+			return report, err
+		}
+		if now.Sub(existing.Time) > reportTTL {
+			reports.Delete(key)
+			continue
+		}
+		if existing.ChatID == report.ChatID && existing.MessageID == report.MessageID {
+			return report, ErrDuplicated
+		}
+	}
+
+	report.ID = strconv.FormatInt(now.UnixNano(), 36)
+	report.Time = now
+	if err := reports.Put(reportKey(report.ID), report); err != nil {
+		return report, err
+	}
+	lastReports[report.Reporter.ID] = now
+	return report, nil
+}
+
+// SetNotices records the messages telling the admins about the report, so
+// they can be updated once handled.
+func SetNotices(id string, notices []Notice) error {
+	reportsMu.Lock()
+	defer reportsMu.Unlock()
+	if reports == nil {
+		return errNotInitialized
+	}
+
+	var report Report
+	if ok, err := reports.Get(reportKey(id), &report); err != nil || !ok {
+		return err
+	}
+	report.Notices = notices
+	return reports.Put(reportKey(id), report)
+}
+
+// Get returns a pending report.
+func Get(id string) (Report, error) {
+	reportsMu.Lock()
+	defer reportsMu.Unlock()
+	return get(id)
+}
+
+// Resolve removes the pending report and returns it. Only the first admin
+// resolving a report gets it.
+func Resolve(id string) (Report, error) {
+	reportsMu.Lock()
+	defer reportsMu.Unlock()
+	report, err := get(id)
+	if err != nil {
+		return report, err
+	}
+	return report, reports.Delete(reportKey(id))
+}
+
+// Restore puts back a resolved report whose action failed, so it can be
+// handled again.
+func Restore(report Report) error {
+	reportsMu.Lock()
+	defer reportsMu.Unlock()
+	if reports == nil {
+		return errNotInitialized
+	}
+	return reports.Put(reportKey(report.ID), report)
+}
+
+// get loads a report. Must be called with reportsMu held.
+func get(id string) (Report, error) {
+	var report Report
+	if reports == nil {
+		return report, errNotInitialized
+	}
+	ok, err := reports.Get(reportKey(id), &report)
+	if err == nil && !ok {
+		err = ErrNotFound
+	}
+	return report, err
+}
+
+func reportKey(id string) string {
+	return fmt.Sprintf("%s%s", reportKeyPrefix, id)
+}
@@ -0,0 +1,105 @@
+package router
+
+import (
+	"context"
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/callback"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+	"go.uber.org/zap"
+)
+
+const (
+	invalidCallbackAnswer = "🙅🏻‍♀️ Esta acción ya no está disponible"
+)
+
+// Answer is shown to the user that pressed the button once the callback
+// query is handled. An empty answer just stops the button loading animation.
+type Answer struct {
+	Text  string
+	Alert bool // Show the text in an alert instead of a notification
+}
+
+// CallbackFunc handles a callback query addressed to a namespace.
+type CallbackFunc func(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, query *tgbotapi.CallbackQuery, data callback.Data) Answer
+
+// CallbackMiddleware wraps the handler of a callback.
+type CallbackMiddleware func(cb *Callback, next CallbackFunc) CallbackFunc
+
+// Callback describes how to handle the callback queries of a namespace.
+type Callback struct {
+	Namespace string // First field of the callback data
+	Scope     Scope  // Where and by whom the buttons can be pressed
+	Handler   CallbackFunc
+}
+
+// UseCallback appends middlewares to the callback chain. The first
+// middleware is the outermost.
+func (r *Router) UseCallback(middlewares ...CallbackMiddleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.callbackMiddlewares = append(r.callbackMiddlewares, middlewares...)
+}
+
+// RegisterCallback adds callbacks to the router. It panics if a namespace is
+// already taken, as that is always a programming error.
+func (r *Router) RegisterCallback(callbacks ...Callback) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range callbacks {
+		cb := callbacks[i]
+		if cb.Handler == nil {
+			panic(fmt.Sprintf("router: callback %q has no handler", cb.Namespace))
+		}
+		if _, exists := r.callbacks[cb.Namespace]; exists {
+			panic(fmt.Sprintf("router: callback %q registered twice", cb.Namespace))
+		}
+		if cb.Scope == 0 {
+			cb.Scope = ScopeAll
+		}
+		r.callbacks[cb.Namespace] = &cb
+	}
+}
+
+// DispatchCallback runs the callback addressed by the update callback query
+// and answers the query. It returns false when the update is not a callback
+// query.
+func (r *Router) DispatchCallback(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) bool {
+	query := update.CallbackQuery
+	if query == nil {
+		return false
+	}
+
+	answer := Answer{Text: invalidCallbackAnswer}
+	defer func() {
+		config := tgbotapi.NewCallback(query.ID, answer.Text)
+		config.ShowAlert = answer.Alert
+		hebeBot.Enqueue(config)
+	}()
+
+	data, err := callback.Decode(query.Data)
+	if err != nil {
+		logger.Warn("Invalid callback data", zap.String("data", query.Data), zap.Error(err))
+		return true
+	}
+
+	r.mu.RLock()
+	cb, ok := r.callbacks[data.Namespace]
+	middlewares := r.callbackMiddlewares
+	r.mu.RUnlock()
+	if !ok {
+		logger.Warn("Unknown callback namespace", zap.String("namespace", data.Namespace))
+		return true
+	}
+
+	handler := cb.Handler
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](cb, handler)
+	}
+	answer = handler(ctx, logger, hebeBot, query, data)
+
+	return true
+}
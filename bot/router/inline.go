@@ -0,0 +1,100 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+	"go.uber.org/zap"
+)
+
+// InlineFunc builds the results of an inline query. args is the query text
+// after the keyword.
+type InlineFunc func(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, query *tgbotapi.InlineQuery, args string) []interface{}
+
+// Inline describes how to answer the inline queries starting with a keyword
+// (@hebebot keyword args...).
+type Inline struct {
+	Keyword string
+	Aliases []string // Alternative keywords, "" handles the empty queries
+	Handler InlineFunc
+}
+
+// InlineOptions configures the answers to inline queries.
+type InlineOptions struct {
+	CacheTime  int  // Seconds Telegram may cache the results
+	IsPersonal bool // Results depend on the user, don't share the cache between users
+}
+
+// RegisterInline adds inline query handlers to the router. It panics if a
+// keyword is already taken, as that is always a programming error.
+func (r *Router) RegisterInline(inlines ...Inline) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range inlines {
+		inline := inlines[i]
+		if inline.Handler == nil {
+			panic(fmt.Sprintf("router: inline %q has no handler", inline.Keyword))
+		}
+		for _, keyword := range append([]string{inline.Keyword}, inline.Aliases...) {
+			keyword = strings.ToLower(keyword)
+			if _, exists := r.inlines[keyword]; exists {
+				panic(fmt.Sprintf("router: inline %q registered twice", keyword))
+			}
+			r.inlines[keyword] = &inline
+		}
+	}
+}
+
+// DispatchInline answers the update inline query with the results of the
+// handler registered for its keyword. Unknown keywords get no results. It
+// returns false when the update is not an inline query.
+func (r *Router) DispatchInline(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update, options InlineOptions) bool {
+	query := update.InlineQuery
+	if query == nil {
+		return false
+	}
+
+	fields := strings.SplitN(strings.TrimSpace(query.Query), " ", 2)
+	keyword, args := strings.ToLower(fields[0]), ""
+	if len(fields) == 2 {
+		args = strings.TrimSpace(fields[1])
+	}
+
+	r.mu.RLock()
+	inline, ok := r.inlines[keyword]
+	r.mu.RUnlock()
+
+	results := []interface{}{}
+	if ok {
+		results = r.runInline(ctx, logger, hebeBot, inline, query, args)
+	}
+
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		Results:       results,
+		CacheTime:     options.CacheTime,
+		IsPersonal:    options.IsPersonal,
+	}
+	if _, err := hebeBot.Request(answer); err != nil {
+		logger.Error("Unable to answer inline query", zap.String("query", query.Query), zap.Error(err))
+	}
+	return true
+}
+
+func (r *Router) runInline(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, inline *Inline, query *tgbotapi.InlineQuery, args string) (results []interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("Inline handler panicked",
+				zap.String("keyword", inline.Keyword),
+				zap.Any("panic", r),
+				zap.Stack("stack"))
+			results = []interface{}{}
+		}
+	}()
+	return inline.Handler(ctx, logger, hebeBot, query, args)
+}
@@ -0,0 +1,151 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+)
+
+// HandlerFunc handles a single update addressed to a command.
+type HandlerFunc func(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update)
+
+// Middleware wraps the handler of a command. It receives the command being
+// dispatched so it can act upon its metadata (scope, name...).
+type Middleware func(command *Command, next HandlerFunc) HandlerFunc
+
+// Command describes a bot command and how to handle it.
+type Command struct {
+	Name        string            // Command name without the leading slash
+	Aliases     []string          // Alternative names for the command
+	Description string            // Short description shown in /help, in Spanish
+	Localized   map[string]string // Description in other languages by language code
+	Scope       Scope             // Where and by whom the command can be used
+	Handler     HandlerFunc
+}
+
+// DescriptionIn returns the description of the command in the given
+// language, falling back to the Spanish one.
+func (c Command) DescriptionIn(language string) string {
+	if description, ok := c.Localized[language]; ok {
+		return description
+	}
+	return c.Description
+}
+
+// Router dispatches command updates, callback queries and inline queries to
+// the registered handlers.
+type Router struct {
+	mu          sync.RWMutex
+	commands    []*Command
+	index       map[string]*Command
+	middlewares []Middleware
+
+	callbacks           map[string]*Callback
+	callbackMiddlewares []CallbackMiddleware
+
+	inlines map[string]*Inline
+}
+
+func New() *Router {
+	return &Router{
+		index:     make(map[string]*Command),
+		callbacks: make(map[string]*Callback),
+		inlines:   make(map[string]*Inline),
+	}
+}
+
+// Use appends middlewares to the chain. The first middleware is the outermost.
+func (r *Router) Use(middlewares ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// Register adds commands to the router. It panics if a command name or alias
+// is already taken, as that is always a programming error.
+func (r *Router) Register(commands ...Command) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range commands {
+		command := commands[i]
+		if command.Handler == nil {
+			panic(fmt.Sprintf("router: command %q has no handler", command.Name))
+		}
+		if command.Scope == 0 {
+			command.Scope = ScopeAll
+		}
+
+		for _, name := range append([]string{command.Name}, command.Aliases...) {
+			name = strings.ToLower(name)
+			if _, exists := r.index[name]; exists {
+				panic(fmt.Sprintf("router: command %q registered twice", name))
+			}
+			r.index[name] = &command
+		}
+		r.commands = append(r.commands, &command)
+	}
+}
+
+// Lookup returns the command registered under name or one of its aliases.
+func (r *Router) Lookup(name string) (Command, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	command, ok := r.index[strings.ToLower(name)]
+	if !ok {
+		return Command{}, false
+	}
+	return *command, true
+}
+
+// Commands returns the registered commands sorted by name.
+func (r *Router) Commands() []Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	commands := make([]Command, 0, len(r.commands))
+	for _, command := range r.commands {
+		commands = append(commands, *command)
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Name < commands[j].Name
+	})
+	return commands
+}
+
+// Dispatch runs the command addressed by the update message. It returns false
+// when the message is not a known command for this bot.
+func (r *Router) Dispatch(ctx context.Context, logger *logs.Logger, hebeBot botapi.Bot, update tgbotapi.Update) bool {
+	if update.Message == nil || !update.Message.IsCommand() {
+		return false
+	}
+
+	// Ignore commands addressed to other bots (/rules@OtherBot)
+	if at := strings.SplitN(update.Message.CommandWithAt(), "@", 2); len(at) == 2 &&
+		!strings.EqualFold(at[1], hebeBot.Self().UserName) {
+		return false
+	}
+
+	r.mu.RLock()
+	command, ok := r.index[strings.ToLower(update.Message.Command())]
+	middlewares := r.middlewares
+	r.mu.RUnlock()
+	if !ok {
+		return false
+	}
+
+	handler := command.Handler
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](command, handler)
+	}
+	handler(ctx, logger, hebeBot, update)
+
+	return true
+}
@@ -0,0 +1,35 @@
+package router
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Scope is a bit set restricting where and by whom a command can be used.
+type Scope uint8
+
+const (
+	ScopeGroup   Scope = 1 << iota // Usable in groups and supergroups
+	ScopePrivate                   // Usable in private chats with the bot
+	ScopeAdmin                     // Only usable by chat administrators
+	ScopeOwner                     // Only usable by the bot owners
+
+	ScopeAll = ScopeGroup | ScopePrivate
+)
+
+// Has reports whether all the bits of other are set in s.
+func (s Scope) Has(other Scope) bool {
+	return s&other == other
+}
+
+// AllowsChat reports whether a command with this scope can be used in chat.
+func (s Scope) AllowsChat(chat *tgbotapi.Chat) bool {
+	switch {
+	case chat == nil:
+		return false
+	case chat.IsPrivate():
+		return s.Has(ScopePrivate)
+	case chat.IsGroup(), chat.IsSuperGroup():
+		return s.Has(ScopeGroup)
+	}
+	return false
+}
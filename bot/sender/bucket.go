@@ -0,0 +1,40 @@
+package sender
+
+import (
+	"sync"
+	"time"
+)
+
+// bucket is a token bucket rate limiter. A token is earned every interval,
+// up to burst tokens.
+type bucket struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    float64
+	tokens   float64
+	last     time.Time
+}
+
+func newBucket(interval time.Duration, burst int) *bucket {
+	return &bucket{interval: interval, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// take reserves a token and returns how long the caller has to wait before
+// using it.
+func (b *bucket) take() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += float64(now.Sub(b.last)) / float64(b.interval)
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens * float64(b.interval))
+}
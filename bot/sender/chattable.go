@@ -0,0 +1,102 @@
+package sender
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// chatOf returns the chat a request is sent to, or 0 if it is not tied to a
+// chat.
+func chatOf(c tgbotapi.Chattable) int64 {
+	switch c := c.(type) {
+	case tgbotapi.MessageConfig:
+		return c.ChatID
+	case tgbotapi.ForwardConfig:
+		return c.ChatID
+	case tgbotapi.CopyMessageConfig:
+		return c.ChatID
+	case tgbotapi.PhotoConfig:
+		return c.ChatID
+	case tgbotapi.DocumentConfig:
+		return c.ChatID
+	case tgbotapi.StickerConfig:
+		return c.ChatID
+	case tgbotapi.AnimationConfig:
+		return c.ChatID
+	case tgbotapi.ChatActionConfig:
+		return c.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		return c.ChatID
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return c.ChatID
+	case tgbotapi.DeleteMessageConfig:
+		return c.ChatID
+	case tgbotapi.PinChatMessageConfig:
+		return c.ChatID
+	case tgbotapi.BanChatMemberConfig:
+		return c.ChatID
+	case tgbotapi.UnbanChatMemberConfig:
+		return c.ChatID
+	case tgbotapi.RestrictChatMemberConfig:
+		return c.ChatID
+	case tgbotapi.SetChatPermissionsConfig:
+		return c.ChatID
+	case tgbotapi.LeaveChatConfig:
+		return c.ChatID
+	case tgbotapi.GetChatMemberConfig:
+		return c.ChatID
+	case tgbotapi.ChatAdministratorsConfig:
+		return c.ChatID
+	case tgbotapi.ChatInfoConfig:
+		return c.ChatID
+	case tgbotapi.ChatMemberCountConfig:
+		return c.ChatID
+	}
+	return 0
+}
+
+// direct reports whether c is sent right away instead of after the requests
+// queued for its chat, only limited by the global rate. Callback and inline
+// query answers are only accepted for a short time, and reads or requests
+// not tied to a chat do not need any order.
+func direct(c tgbotapi.Chattable) bool {
+	switch c.(type) {
+	case tgbotapi.CallbackConfig,
+		tgbotapi.InlineConfig,
+		tgbotapi.GetChatMemberConfig,
+		tgbotapi.ChatAdministratorsConfig,
+		tgbotapi.ChatInfoConfig,
+		tgbotapi.ChatMemberCountConfig,
+		tgbotapi.FileConfig,
+		tgbotapi.GetMyCommandsConfig,
+		tgbotapi.SetMyCommandsConfig,
+		tgbotapi.DeleteMyCommandsConfig:
+		return true
+	}
+	return false
+}
+
+// sendsMessage reports whether c posts a message, which count against the
+// per group limit.
+func sendsMessage(c tgbotapi.Chattable) bool {
+	switch c.(type) {
+	case tgbotapi.MessageConfig,
+		tgbotapi.ForwardConfig,
+		tgbotapi.CopyMessageConfig,
+		tgbotapi.PhotoConfig,
+		tgbotapi.DocumentConfig,
+		tgbotapi.StickerConfig,
+		tgbotapi.AnimationConfig,
+		tgbotapi.EditMessageTextConfig:
+		return true
+	}
+	return false
+}
+
+// createsMessage reports whether sending c twice posts the message twice,
+// unlike edits which leave the message the same.
+func createsMessage(c tgbotapi.Chattable) bool {
+	if _, ok := c.(tgbotapi.EditMessageTextConfig); ok {
+		return false
+	}
+	return sendsMessage(c)
+}
@@ -0,0 +1,356 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+	"go.uber.org/zap"
+)
+
+// ErrClosed is returned for the requests enqueued after the sender is
+// closed or dropped while closing.
+var ErrClosed = errors.New("sender is closed")
+
+const (
+	initialBackoff = 500 * time.Millisecond
+	maxBackoff     = 30 * time.Second
+)
+
+// Options configures the sender limits.
+type Options struct {
+	GlobalPerSecond int // Requests per second to the whole Bot API
+	GroupPerMinute  int // Messages per minute to a single group
+	MaxRetries      int // Retries of a failed request before giving up
+}
+
+type job struct {
+	ctx       context.Context // Dropped when done before it is sent
+	chattable tgbotapi.Chattable
+	result    chan botapi.Result
+}
+
+// Sender is the single way out to Telegram. Requests are queued per chat,
+// keeping their order, and sent respecting the Telegram rate limits and the
+// RetryAfter of 429 responses. Failed requests are retried with backoff when
+// the failure is transient.
+type Sender struct {
+	bot botapi.Bot
+
+	logger     *logs.Logger
+	options    Options
+	global     *bucket
+	maxRetries int
+
+	mu          sync.Mutex
+	lanes       map[int64][]*job
+	groups      map[int64]*bucket
+	pausedUntil time.Time
+	closed      bool
+	wg          sync.WaitGroup
+	quit        chan struct{}
+}
+
+// New returns a sender implementing botapi.Bot on top of bot.
+func New(bot botapi.Bot, logger *logs.Logger, options Options) *Sender {
+	if options.GlobalPerSecond < 1 {
+		options.GlobalPerSecond = 30
+	}
+	if options.GroupPerMinute < 1 {
+		options.GroupPerMinute = 20
+	}
+
+	return &Sender{
+		bot:        bot,
+		logger:     logger,
+		options:    options,
+		global:     newBucket(time.Second/time.Duration(options.GlobalPerSecond), options.GlobalPerSecond),
+		maxRetries: options.MaxRetries,
+		lanes:      make(map[int64][]*job),
+		groups:     make(map[int64]*bucket),
+		quit:       make(chan struct{}),
+	}
+}
+
+// Enqueue queues c to be sent. The result can be awaited on the returned
+// channel or ignored, failures are logged anyway.
+func (s *Sender) Enqueue(c tgbotapi.Chattable) <-chan botapi.Result {
+	return s.enqueue(context.Background(), c)
+}
+
+// Send enqueues c and waits for the sent message.
+func (s *Sender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return s.SendContext(context.Background(), c)
+}
+
+// Request enqueues c and waits for the Bot API response.
+func (s *Sender) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	return s.RequestContext(context.Background(), c)
+}
+
+// SendContext is Send giving up when ctx is done. The request is dropped
+// unless it is already being sent.
+func (s *Sender) SendContext(ctx context.Context, c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	result := s.await(ctx, c)
+	return result.Message, result.Err
+}
+
+// RequestContext is Request giving up when ctx is done, like SendContext.
+func (s *Sender) RequestContext(ctx context.Context, c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	result := s.await(ctx, c)
+	return result.Response, result.Err
+}
+
+// WithContext returns the sender as a botapi.Bot whose Send and Request give
+// up when ctx is done, for the handlers to stop waiting once cancelled.
+func (s *Sender) WithContext(ctx context.Context) botapi.Bot {
+	return &boundSender{Sender: s, ctx: ctx}
+}
+
+func (s *Sender) await(ctx context.Context, c tgbotapi.Chattable) botapi.Result {
+	select {
+	case result := <-s.enqueue(ctx, c):
+		return result
+	case <-ctx.Done():
+		return botapi.Result{Err: ctx.Err()}
+	}
+}
+
+func (s *Sender) enqueue(ctx context.Context, c tgbotapi.Chattable) <-chan botapi.Result {
+	j := &job{ctx: ctx, chattable: c, result: make(chan botapi.Result, 1)}
+	chatId := chatOf(c)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		j.result <- botapi.Result{Err: ErrClosed}
+		return j.result
+	}
+
+	// Answers and reads do not wait for the requests queued for their chat
+	if direct(c) {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			j.result <- s.send(chatId, j)
+		}()
+		return j.result
+	}
+
+	lane, running := s.lanes[chatId]
+	s.lanes[chatId] = append(lane, j)
+	if !running {
+		s.wg.Add(1)
+		go s.run(chatId)
+	}
+	return j.result
+}
+
+// GetUpdatesChan is not queued, updates are received straight from the bot.
+func (s *Sender) GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel {
+	return s.bot.GetUpdatesChan(config)
+}
+
+func (s *Sender) StopReceivingUpdates() {
+	s.bot.StopReceivingUpdates()
+}
+
+func (s *Sender) Self() tgbotapi.User {
+	return s.bot.Self()
+}
+
+// Close stops accepting requests and waits until the queued ones are sent
+// or ctx expires. Requests still queued when ctx expires are dropped.
+func (s *Sender) Close(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		close(s.quit)
+		<-done
+		return ctx.Err()
+	}
+}
+
+// run sends the requests queued for chatId one at a time, until the lane is empty.
+func (s *Sender) run(chatId int64) {
+	defer s.wg.Done()
+
+	for {
+		s.mu.Lock()
+		lane := s.lanes[chatId]
+		if len(lane) == 0 {
+			delete(s.lanes, chatId)
+			s.mu.Unlock()
+			return
+		}
+		j := lane[0]
+		s.lanes[chatId] = lane[1:]
+		s.mu.Unlock()
+
+		j.result <- s.send(chatId, j)
+	}
+}
+
+func (s *Sender) send(chatId int64, j *job) botapi.Result {
+	c := j.chattable
+	backoff := initialBackoff
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-s.quit:
+			return botapi.Result{Err: ErrClosed}
+		case <-j.ctx.Done():
+			return botapi.Result{Err: j.ctx.Err()}
+		default:
+		}
+
+		if err := s.wait(j.ctx, s.limitDelay(chatId, c)); err != nil {
+			return botapi.Result{Err: err}
+		}
+
+		resp, err := s.bot.Request(c)
+		if err == nil {
+			return botapi.NewResult(resp, nil)
+		}
+
+		retryIn, transient := s.retryDelay(chatId, c, err, backoff)
+		if !transient || attempt >= s.maxRetries {
+			s.logger.Error("Unable to send request to Telegram",
+				zap.String("request", fmt.Sprintf("%T", c)),
+				zap.Int64("chat", chatId),
+				zap.Int("attempts", attempt+1),
+				zap.Error(err))
+			return botapi.Result{Response: resp, Err: err}
+		}
+
+		s.logger.Debug("Retrying request to Telegram",
+			zap.String("request", fmt.Sprintf("%T", c)),
+			zap.Int64("chat", chatId),
+			zap.Duration("in", retryIn),
+			zap.Error(err))
+		if err := s.wait(j.ctx, retryIn); err != nil {
+			return botapi.Result{Err: err}
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// limitDelay reserves the rate limit tokens needed to send c and returns
+// how long to wait for them.
+func (s *Sender) limitDelay(chatId int64, c tgbotapi.Chattable) time.Duration {
+	delay := s.global.take()
+
+	// Groups have their own limit on the messages sent to them. Group ids are
+	// negative, user ids positive
+	if chatId < 0 && sendsMessage(c) {
+		s.mu.Lock()
+		group, ok := s.groups[chatId]
+		if !ok {
+			group = newBucket(time.Minute/time.Duration(s.options.GroupPerMinute), s.options.GroupPerMinute)
+			s.groups[chatId] = group
+		}
+		s.mu.Unlock()
+
+		if groupDelay := group.take(); groupDelay > delay {
+			delay = groupDelay
+		}
+	}
+
+	s.mu.Lock()
+	if paused := time.Until(s.pausedUntil); paused > delay {
+		delay = paused
+	}
+	s.mu.Unlock()
+
+	return delay
+}
+
+// retryDelay decides whether err is worth a retry and how long to wait for it.
+// Messages are only sent again when Telegram certainly did not post them,
+// so a retry never duplicates them.
+func (s *Sender) retryDelay(chatId int64, c tgbotapi.Chattable, err error, backoff time.Duration) (time.Duration, bool) {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		// Network errors, the request may have reached Telegram unless the
+		// connection could not even be made
+		return backoff, !createsMessage(c) || notSent(err)
+	}
+
+	if apiErr.RetryAfter > 0 {
+		retryAfter := time.Duration(apiErr.RetryAfter) * time.Second
+		// Flood limits not tied to a chat stop every request
+		if chatId == 0 {
+			s.mu.Lock()
+			s.pausedUntil = time.Now().Add(retryAfter)
+			s.mu.Unlock()
+		}
+		return retryAfter, true
+	}
+
+	return backoff, apiErr.Code >= 500 && !createsMessage(c)
+}
+
+// notSent reports whether err happened before the request was written, while
+// resolving or connecting to the Bot API.
+func notSent(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// wait sleeps for d. It fails if the sender is closed or ctx is done
+// meanwhile.
+func (s *Sender) wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-s.quit:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// boundSender is a Sender whose Send and Request give up when ctx is done
+type boundSender struct {
+	*Sender
+	ctx context.Context
+}
+
+func (b *boundSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return b.SendContext(b.ctx, c)
+}
+
+func (b *boundSender) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	return b.RequestContext(b.ctx, c)
+}
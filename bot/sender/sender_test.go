@@ -0,0 +1,124 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+)
+
+// blockingBot holds the messages until released, and answers anything else
+// right away
+type blockingBot struct {
+	botapi.Bot
+	release chan struct{}
+}
+
+func (b *blockingBot) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	if _, ok := c.(tgbotapi.MessageConfig); ok {
+		<-b.release
+	}
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+// newBlockedSender returns a sender with the lane of chat -1 blocked.
+func newBlockedSender(t *testing.T) *Sender {
+	logger, err := logs.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	s := New(&blockingBot{release: release}, logger, Options{})
+	t.Cleanup(func() {
+		close(release)
+		s.Close(context.Background())
+	})
+	s.Enqueue(tgbotapi.NewMessage(-1, "first"))
+	return s
+}
+
+func TestSendContextCancelled(t *testing.T) {
+	s := newBlockedSender(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.WithContext(ctx).Send(tgbotapi.NewMessage(-1, "second"))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got %v, want the context error", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Send did not give up once the context was done")
+	}
+}
+
+func TestDirectRequestsSkipLanes(t *testing.T) {
+	s := newBlockedSender(t)
+
+	requests := []tgbotapi.Chattable{
+		tgbotapi.NewCallback("query", "done"),
+		tgbotapi.GetChatMemberConfig{ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: -1, UserID: 1}},
+		tgbotapi.ChatAdministratorsConfig{ChatConfig: tgbotapi.ChatConfig{ChatID: -1}},
+	}
+	for _, c := range requests {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		if _, err := s.RequestContext(ctx, c); err != nil {
+			t.Errorf("%T waited for the chat lane: %v", c, err)
+		}
+		cancel()
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	message := tgbotapi.NewMessage(1, "hola")
+	edit := tgbotapi.NewEditMessageText(1, 2, "hola")
+	deletion := tgbotapi.NewDeleteMessage(1, 2)
+
+	dialErr := &url.Error{Op: "Post", URL: "https://api", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}
+	readErr := &url.Error{Op: "Post", URL: "https://api", Err: &net.OpError{Op: "read", Err: errors.New("connection reset")}}
+	timeout := &url.Error{Op: "Post", URL: "https://api", Err: errors.New("Client.Timeout exceeded")}
+	flood := &tgbotapi.Error{Code: 429, Message: "Too Many Requests", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 3}}
+	serverErr := &tgbotapi.Error{Code: 502, Message: "Bad Gateway"}
+	badRequest := &tgbotapi.Error{Code: 400, Message: "Bad Request"}
+
+	tests := []struct {
+		name      string
+		c         tgbotapi.Chattable
+		err       error
+		transient bool
+	}{
+		{"message not connected", message, dialErr, true},
+		{"message read error", message, readErr, false},
+		{"message timeout", message, timeout, false},
+		{"message flood", message, flood, true},
+		{"message server error", message, serverErr, false},
+		{"message bad request", message, badRequest, false},
+		{"edit timeout", edit, timeout, true},
+		{"edit server error", edit, serverErr, true},
+		{"delete read error", deletion, readErr, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &Sender{}
+			delay, transient := s.retryDelay(1, test.c, test.err, time.Second)
+			if transient != test.transient {
+				t.Errorf("transient = %v, want %v", transient, test.transient)
+			}
+			if test.err == error(flood) && delay != 3*time.Second {
+				t.Errorf("delay = %s, want the RetryAfter", delay)
+			}
+		})
+	}
+}
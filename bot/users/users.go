@@ -0,0 +1,98 @@
+// Package users remembers the users seen by the bot. The Bot API can not
+// resolve the username of a user, so commands targeting a @username look it
+// up here.
+package users
+
+import (
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/internal/store"
+)
+
+// Store keys of the seen users and the users that started the bot
+const (
+	usersKey   = "users.seen"
+	startedKey = "users.started"
+)
+
+// Seen users by lowercase username, and users that talked to the bot in
+// private so the bot can message them
+var (
+	seen    = make(map[string]tgbotapi.User)
+	started = make(map[int64]bool)
+	seenMu  sync.Mutex
+)
+
+// Seen remembers the users of an update.
+func Seen(update tgbotapi.Update) {
+	if update.Message == nil {
+		return
+	}
+	remember(update.Message.From)
+	if update.Message.Chat.IsPrivate() && update.Message.From != nil {
+		seenMu.Lock()
+		started[update.Message.From.ID] = true
+		seenMu.Unlock()
+	}
+	for i := range update.Message.NewChatMembers {
+		remember(&update.Message.NewChatMembers[i])
+	}
+	if reply := update.Message.ReplyToMessage; reply != nil {
+		remember(reply.From)
+	}
+}
+
+// Started reports whether the user started a private chat with the bot.
+func Started(userId int64) bool {
+	seenMu.Lock()
+	defer seenMu.Unlock()
+	return started[userId]
+}
+
+// Lookup returns the user with the username, with or without the leading @.
+func Lookup(username string) (tgbotapi.User, bool) {
+	username = strings.ToLower(strings.TrimPrefix(username, "@"))
+	seenMu.Lock()
+	defer seenMu.Unlock()
+	user, ok := seen[username]
+	return user, ok
+}
+
+func remember(user *tgbotapi.User) {
+	if user == nil || user.UserName == "" || user.IsBot {
+		return
+	}
+	username := strings.ToLower(user.UserName)
+	seenMu.Lock()
+	seen[username] = *user
+	seenMu.Unlock()
+}
+
+// LoadState restores the users seen before the last shutdown.
+func LoadState(st *store.Store) error {
+	users := make(map[string]tgbotapi.User)
+	if _, err := st.Get(usersKey, &users); err != nil {
+		return err
+	}
+	startedUsers := make(map[int64]bool)
+	if _, err := st.Get(startedKey, &startedUsers); err != nil {
+		return err
+	}
+	seenMu.Lock()
+	seen = users
+	started = startedUsers
+	seenMu.Unlock()
+	return nil
+}
+
+// SaveState persists the seen users.
+func SaveState(st *store.Store) error {
+	seenMu.Lock()
+	defer seenMu.Unlock()
+	if err := st.Put(usersKey, seen); err != nil {
+		return err
+	}
+	return st.Put(startedKey, started)
+}
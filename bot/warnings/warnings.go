@@ -0,0 +1,151 @@
+// Package warnings keeps the ledger of the warnings given to the chat
+// members and escalates to a moderation action when a user gets too many.
+package warnings
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/bot/botapi"
+	"github.com/hestingames/hg-hebe-bot/bot/moderation"
+	"github.com/hestingames/hg-hebe-bot/config"
+	"github.com/hestingames/hg-hebe-bot/internal/logs"
+	"github.com/hestingames/hg-hebe-bot/internal/store"
+)
+
+// Prefix of the store keys of the warnings, followed by chat and user ids
+const warningsKeyPrefix = "warnings."
+
+var errNotInitialized = errors.New("warnings ledger not initialized")
+
+// Warning given to a chat member
+type Warning struct {
+	ChatID    int64
+	UserID    int64
+	AdminID   int64
+	AdminName string
+	Reason    string
+	Time      time.Time
+}
+
+var (
+	ledger   *store.Store
+	ledgerMu sync.Mutex // Serializes the read-modify-write of the warnings
+)
+
+// Initialize sets the store the warnings are persisted in.
+func Initialize(st *store.Store) {
+	ledgerMu.Lock()
+	ledger = st
+	ledgerMu.Unlock()
+}
+
+// Add records a warning and returns every warning of the user in the chat.
+func Add(warning Warning) ([]Warning, error) {
+	ledgerMu.Lock()
+	defer ledgerMu.Unlock()
+	if ledger == nil {
+		return nil, errNotInitialized
+	}
+
+	key := warningsKey(warning.ChatID, warning.UserID)
+	var list []Warning
+	if _, err := ledger.Get(key, &list); err != nil {
+		return nil, err
+	}
+	list = append(list, warning)
+	return list, ledger.Put(key, list)
+}
+
+// List returns the warnings of the user in the chat, oldest first.
+func List(chatId, userId int64) ([]Warning, error) {
+	ledgerMu.Lock()
+	defer ledgerMu.Unlock()
+	if ledger == nil {
+		return nil, errNotInitialized
+	}
+
+	var list []Warning
+	_, err := ledger.Get(warningsKey(chatId, userId), &list)
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Time.Before(list[j].Time) })
+	return list, err
+}
+
+// Reset removes the warnings of the user in the chat.
+func Reset(chatId, userId int64) error {
+	ledgerMu.Lock()
+	defer ledgerMu.Unlock()
+	if ledger == nil {
+		return errNotInitialized
+	}
+	return ledger.Delete(warningsKey(chatId, userId))
+}
+
+// Escalation returns the moderation action a user reaching count warnings
+// in the chat gets, if any. Users going past the last threshold get the
+// action of the last threshold again.
+func Escalation(chatId int64, count int) (moderation.Action, bool, error) {
+	thresholds := config.AppConfig.WarnThresholds(chatId)
+	if len(thresholds) == 0 {
+		return moderation.Action{}, false, nil
+	}
+	sort.Slice(thresholds, func(i, j int) bool { return thresholds[i].Warns < thresholds[j].Warns })
+
+	var threshold config.WarnThreshold
+	found := false
+	for _, t := range thresholds {
+		if t.Warns == count {
+			threshold, found = t, true
+		}
+	}
+	if last := thresholds[len(thresholds)-1]; count > last.Warns {
+		threshold, found = last, true
+	}
+	if !found {
+		return moderation.Action{}, false, nil
+	}
+
+	action := moderation.Action{
+		Kind:   moderation.Kind(threshold.Action),
+		ChatID: chatId,
+		Reason: fmt.Sprintf("%d advertencias", count),
+	}
+	switch action.Kind {
+	case moderation.Ban, moderation.Kick, moderation.Mute:
+	default:
+		return moderation.Action{}, false, fmt.Errorf("invalid warning threshold action %q", threshold.Action)
+	}
+	if threshold.Duration != "" {
+		duration, err := moderation.ParseDuration(threshold.Duration)
+		if err != nil {
+			return moderation.Action{}, false, fmt.Errorf("invalid warning threshold duration %q", threshold.Duration)
+		}
+		if duration > 0 {
+			action.Until = time.Now().Add(duration)
+		}
+	}
+	return action, true, nil
+}
+
+// Escalate applies the escalation of a user reaching count warnings in the
+// chat. It returns the action applied, if any.
+func Escalate(logger *logs.Logger, hebeBot botapi.Bot, chatId int64, target tgbotapi.User, count int) (moderation.Action, bool, error) {
+	action, ok, err := Escalation(chatId, count)
+	if err != nil || !ok {
+		return action, false, err
+	}
+	action.Target = target
+	action.Admin = hebeBot.Self()
+	if err := moderation.Apply(logger, hebeBot, action); err != nil {
+		return action, false, err
+	}
+	return action, true, nil
+}
+
+func warningsKey(chatId, userId int64) string {
+	return fmt.Sprintf("%s%d.%d", warningsKeyPrefix, chatId, userId)
+}
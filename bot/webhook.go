@@ -0,0 +1,230 @@
+package hebe
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hestingames/hg-hebe-bot/config"
+	"github.com/hestingames/hg-hebe-bot/internal/environment"
+	"go.uber.org/zap"
+)
+
+const (
+	// Header Telegram sets on every webhook request with the secret_token
+	// given to setWebhook
+	webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+	// Telegram updates are small, anything bigger is not coming from Telegram
+	webhookMaxBodySize = 1 << 20
+
+	webhookShutdownTimeout = 5 * time.Second
+)
+
+// errWebhookWithoutSecret is returned when starting the webhook without a
+// secret outside local development, anyone knowing the url could post
+// updates to it otherwise.
+var errWebhookWithoutSecret = errors.New("webhook secret must be set outside local development")
+
+// Updates requested to Telegram, chat_member updates are only sent when
+// explicitly requested
+var allowedUpdates = []string{
+	"message",
+	"callback_query",
+	"inline_query",
+	"chat_member",
+	"my_chat_member",
+}
+
+// webhookHandler decodes the updates Telegram posts to the webhook and feeds
+// them to the updates channel.
+//
+// It can be tested locally by posting a recorded update to the listener:
+//
+//	curl -H "X-Telegram-Bot-Api-Secret-Token: <secret>" -d @update.json http://127.0.0.1:8443/
+type webhookHandler struct {
+	secret  string
+	updates chan tgbotapi.Update
+
+	// done is closed when the listener stops, so the requests waiting on
+	// updates give up. mu is held while sending to updates, so it is only
+	// closed once no request is sending to it
+	done     chan struct{}
+	mu       sync.RWMutex
+	closed   bool
+	stopOnce sync.Once
+}
+
+func newWebhookHandler(secret string, buffer int) *webhookHandler {
+	return &webhookHandler{
+		secret:  secret,
+		updates: make(chan tgbotapi.Update, buffer),
+		done:    make(chan struct{}),
+	}
+}
+
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.secret != "" &&
+		subtle.ConstantTimeCompare([]byte(r.Header.Get(webhookSecretHeader)), []byte(h.secret)) != 1 {
+		logger.Warn("Webhook request with an invalid secret token", zap.String("remote", r.RemoteAddr))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var update tgbotapi.Update
+	if err := json.NewDecoder(io.LimitReader(r.Body, webhookMaxBodySize)).Decode(&update); err != nil {
+		logger.Warn("Unable to decode webhook update", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if h.deliver(r.Context(), update) {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		// Telegram will deliver the update again
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+// deliver feeds the update to the updates channel, unless the listener is
+// stopping or the request is cancelled first.
+func (h *webhookHandler) deliver(ctx context.Context, update tgbotapi.Update) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.closed {
+		return false
+	}
+
+	select {
+	case h.updates <- update:
+		return true
+	case <-h.done:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// stop makes the pending and new requests give up and closes the updates
+// channel once none of them is sending to it.
+func (h *webhookHandler) stop() {
+	h.stopOnce.Do(func() {
+		close(h.done)
+		h.mu.Lock()
+		h.closed = true
+		close(h.updates)
+		h.mu.Unlock()
+	})
+}
+
+// startWebhook registers the webhook on Telegram and starts the listener that
+// receives the updates. The returned function stops the listener and removes
+// the webhook.
+func startWebhook(hebeBot *tgbotapi.BotAPI) (tgbotapi.UpdatesChannel, func(), error) {
+	if config.AppConfig.WebhookSecret == "" && !environment.IsLocal() {
+		return nil, nil, errWebhookWithoutSecret
+	}
+
+	// Without an url the webhook is not registered on Telegram, so the
+	// listener can be fed by hand during local development
+	register := config.AppConfig.WebhookUrl != "" || !environment.IsLocal()
+
+	webhookUrl, err := url.Parse(config.AppConfig.WebhookUrl)
+	if register && (err != nil || webhookUrl.Scheme != "https") {
+		return nil, nil, errors.New("webhook url must be a valid https url")
+	}
+
+	path := webhookUrl.Path
+	if path == "" {
+		path = "/"
+	}
+
+	handler := newWebhookHandler(config.AppConfig.WebhookSecret, hebeBot.Buffer)
+	mux := http.NewServeMux()
+	mux.Handle(path, handler)
+
+	server := &http.Server{
+		Addr:              config.AppConfig.WebhookListen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		var err error
+		if config.AppConfig.WebhookCertFile != "" {
+			err = server.ListenAndServeTLS(config.AppConfig.WebhookCertFile, config.AppConfig.WebhookKeyFile)
+		} else {
+			// TLS is terminated by a reverse proxy
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logger.Error("Webhook listener stopped", zap.Error(err))
+			handler.stop()
+		}
+	}()
+
+	if register {
+		params := make(tgbotapi.Params)
+		params["url"] = webhookUrl.String()
+		params.AddNonEmpty("secret_token", config.AppConfig.WebhookSecret)
+		if err := params.AddInterface("allowed_updates", allowedUpdates); err != nil {
+			server.Close()
+			return nil, nil, err
+		}
+		if _, err := hebeBot.MakeRequest("setWebhook", params); err != nil {
+			server.Close()
+			return nil, nil, err
+		}
+	}
+
+	logger.Info("Receiving updates through webhook",
+		zap.String("url", webhookUrl.String()),
+		zap.String("listen", server.Addr))
+
+	stop := func() {
+		if register {
+			if _, err := hebeBot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+				logger.Error("Unable to delete webhook", zap.Error(err))
+			}
+		}
+
+		// The requests still waiting are answered right away, so Shutdown
+		// does not wait for them
+		handler.stop()
+
+		ctx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Error("Unable to stop webhook listener", zap.Error(err))
+		}
+	}
+
+	return handler.updates, stop, nil
+}
+
+// startPolling removes any webhook left behind, as Telegram refuses
+// getUpdates while one is set, and starts long polling.
+func startPolling(hebeBot *tgbotapi.BotAPI) (tgbotapi.UpdatesChannel, func()) {
+	if _, err := hebeBot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		logger.Error("Unable to delete webhook", zap.Error(err))
+	}
+
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = 60
+	u.AllowedUpdates = allowedUpdates
+
+	logger.Info("Receiving updates through long polling")
+	return hebeBot.GetUpdatesChan(u), hebeBot.StopReceivingUpdates
+}
@@ -0,0 +1,86 @@
+package hebe
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hestingames/hg-hebe-bot/config"
+)
+
+func TestWebhookStopWhileDelivering(t *testing.T) {
+	handler := newWebhookHandler("", 0)
+
+	// Nobody reads the updates, so the request blocks on delivering it
+	codes := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"update_id":1}`)))
+		codes <- w.Code
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	handler.stop()
+	select {
+	case code := <-codes:
+		if code != http.StatusServiceUnavailable {
+			t.Errorf("code = %d, want %d", code, http.StatusServiceUnavailable)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request still blocked after stop")
+	}
+	if _, ok := <-handler.updates; ok {
+		t.Error("updates channel not closed")
+	}
+
+	// Requests arriving after stop are refused instead of panicking
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"update_id":2}`)))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("code after stop = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestWebhookSecret(t *testing.T) {
+	handler := newWebhookHandler("secret", 1)
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"wrong", "guess", http.StatusUnauthorized},
+		{"prefix", "secre", http.StatusUnauthorized},
+		{"valid", "secret", http.StatusOK},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"update_id":1}`))
+		if test.header != "" {
+			r.Header.Set(webhookSecretHeader, test.header)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != test.want {
+			t.Errorf("%s secret: code = %d, want %d", test.name, w.Code, test.want)
+		}
+	}
+
+	// Only the update with the valid secret is delivered
+	if len(handler.updates) != 1 {
+		t.Errorf("%d updates delivered, want 1", len(handler.updates))
+	}
+}
+
+func TestWebhookRequiresSecret(t *testing.T) {
+	// The tests run as dev, where the webhook is public
+	secret := config.AppConfig.WebhookSecret
+	config.AppConfig.WebhookSecret = ""
+	defer func() { config.AppConfig.WebhookSecret = secret }()
+
+	if _, _, err := startWebhook(nil); err != errWebhookWithoutSecret {
+		t.Errorf("started without secret: %v", err)
+	}
+}
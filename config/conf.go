@@ -1,27 +1,349 @@
 package config
 
 import (
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/hestingames/hg-hebe-bot/internal/distconf"
 )
 
 const configPath = "config.json"
 
+// Update delivery modes
+const (
+	UpdateModePolling = "polling" // Long polling through getUpdates
+	UpdateModeWebhook = "webhook" // Telegram posts the updates to our listener
+)
+
+// Unauthorized chat actions
+const (
+	UnauthorizedReply  = "reply"  // Reply with the unauthorized message
+	UnauthorizedIgnore = "ignore" // Silently ignore the message
+	UnauthorizedLeave  = "leave"  // Leave the chat
+)
+
+// Inline mode policies
+const (
+	InlineEveryone = "everyone" // Anyone can use the inline mode
+	InlineMembers  = "members"  // Only members of the allowed chats
+	InlineDisabled = "disabled" // Inline queries are ignored
+)
+
+// Captcha modes
+const (
+	CaptchaButton     = "button"     // Press a button
+	CaptchaArithmetic = "arithmetic" // Choose the result of a sum
+	CaptchaDisabled   = "disabled"   // New members are not verified
+)
+
+// Anti-spam filter actions
+const (
+	FilterDelete = "delete" // Delete the message
+	FilterWarn   = "warn"   // Delete the message and warn the user
+	FilterMute   = "mute"   // Delete the message and mute the user
+)
+
+// FilterConfig holds the settings shared by the anti-spam filters.
+type FilterConfig struct {
+	Action string // What to do with the matching messages (delete, warn, mute), disabled when empty
+}
+
+// FiltersConfig holds the settings of the anti-spam filters run on every
+// group message. Admins are exempt from all of them.
+type FiltersConfig struct {
+	Links         FilterConfig // Links and invite links
+	LinkWhitelist []string     // Allowed domains or domain/path prefixes like t.me/hestingames
+
+	Forwards FilterConfig // Messages forwarded from channels
+
+	Words        FilterConfig // Banned words
+	WordPatterns []string     // Case insensitive regular expressions
+
+	Emoji    FilterConfig // Messages with too many emoji
+	MaxEmoji int
+
+	Caps          FilterConfig // Messages written in capital letters
+	CapsMinLength int          // Letters a message needs to be checked
+	CapsMaxRatio  float64      // Maximum ratio of capital letters
+
+	Flood         FilterConfig // Too many messages in a short time
+	FloodMessages int          // Messages allowed within FloodWindow
+	FloodWindow   string       // Like "10s"
+
+	MuteDuration string // Duration of the mutes, like "1h"
+}
+
+// ChatConfig holds the settings of an allowed chat.
+type ChatConfig struct {
+	ID       int64           // Telegram chat id, supergroups are prefixed by -100
+	Name     string          // Human friendly name, only used in logs
+	Welcome  bool            // Welcome new members
+	Commands []string        // Enabled commands, all commands are enabled when empty
+	Warnings []WarnThreshold // Warning escalation, WarnThresholds are used when empty
+	Captcha  string          // Captcha mode, CaptchaMode is used when empty
+}
+
+// WarnThreshold is the moderation action taken when a user reaches a
+// number of warnings.
+type WarnThreshold struct {
+	Warns    int    // Number of warnings
+	Action   string // Moderation action (mute, kick, ban)
+	Duration string // Duration of mutes and bans like "24h", permanent when empty
+}
+
+// CommandEnabled reports whether the command is enabled in the chat.
+func (c ChatConfig) CommandEnabled(name string) bool {
+	if len(c.Commands) == 0 {
+		return true
+	}
+	for _, command := range c.Commands {
+		if command == name {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultChatConfig is used for chats that are not in the allow-list but
+// are served anyway (private chats, local development).
+var defaultChatConfig = ChatConfig{Welcome: true}
+
 type config struct {
-	BotToken   string // Telegram HTTP Api bot token
-	ApiBaseUrl string // CSGOGC api base url
+	BotToken    string // Telegram HTTP Api bot token
+	ApiEndpoint string // Telegram HTTP Api endpoint, format with the token and method
+	ApiBaseUrl  string // CSGOGC api base url
+
+	ApiTimeout   time.Duration // Timeout of each request to the CSGOGC api
+	ApiUserAgent string        // User-Agent of the requests to the CSGOGC api
+	ApiToken     string        // Bearer token of the CSGOGC api, not sent when empty
+	ApiKeyHeader string        // Header carrying ApiKey
+	ApiKey       string        // Key of the CSGOGC api, not sent when empty
+
+	ApiCAFile             string // PEM bundle trusted for the CSGOGC api along with the system roots
+	ApiCertFingerprint    string // SHA-256 of the CSGOGC api certificate, pinned instead of checking its CA
+	ApiClientCertFile     string // Client certificate sent to the CSGOGC api (mTLS)
+	ApiClientKeyFile      string // Key of the client certificate
+	ApiInsecureSkipVerify bool   // Skip the CSGOGC api certificate check, refused in prod
+
+	ApiMaxRetries      *distconf.Int      // Retries of a failed request to the CSGOGC api
+	ApiRetryBaseDelay  *distconf.Duration // Backoff before the first retry, doubled on each retry
+	ApiRetryMaxDelay   *distconf.Duration // Maximum backoff between retries
+	ApiBreakerFailures *distconf.Int      // Consecutive failures after which the api is considered down, disabled when 0
+	ApiBreakerCooldown *distconf.Duration // Time the api is considered down before trying again
+	ApiCacheTTL        *distconf.Duration // Time the CSGOGC api responses are cached, disabled when 0
+	ApiCacheMaxStale   *distconf.Duration // Time past ApiCacheTTL the responses are served while the api fails
+
+	CallbackSecret string // Key signing the callback data, derived from the bot token when empty
+
+	UpdateMode      string // How updates are received (polling, webhook)
+	WebhookUrl      string // Public https url registered on Telegram
+	WebhookListen   string // Address the webhook listener binds to
+	WebhookSecret   string // Secret token Telegram sends on every webhook request, required outside local development
+	WebhookCertFile string // TLS certificate, plain HTTP is used when empty
+	WebhookKeyFile  string // TLS private key
+
+	StatePath       string             // File where the bot state is persisted
+	ShutdownTimeout *distconf.Duration // How long to wait for in-flight updates on shutdown
+	WorkerPoolSize  int64              // Number of updates handled concurrently
+	WorkerQueueSize int64              // Updates waiting on each worker before blocking
+	MetricsListen   string             // Address serving the expvar metrics, disabled when empty
+	SendGlobalRate  int64              // Requests per second sent to Telegram
+	SendGroupRate   int64              // Messages per minute sent to a single group
+	SendMaxRetries  int64              // Retries of a failed request to Telegram
+
+	Chats              *distconf.Struct // Allowed chats ([]ChatConfig)
+	AllowPrivate       *distconf.Bool   // Serve private chats with the bot
+	UnauthorizedAction *distconf.Str    // What to do on unauthorized chats (reply, ignore, leave)
+	InlinePolicy       *distconf.Str    // Who can use the inline mode (everyone, members, disabled)
+	InlineCacheTime    *distconf.Int    // Seconds Telegram may cache the inline results
+
+	Owners        *distconf.Struct   // User ids of the bot owners ([]int64)
+	AdminCacheTTL *distconf.Duration // How long the chat administrators are cached
+
+	DefaultWarnThresholds *distconf.Struct // Warning escalation of the chats without their own ([]WarnThreshold)
+
+	CaptchaMode    *distconf.Str      // Captcha of the chats without their own (button, arithmetic, disabled)
+	CaptchaTimeout *distconf.Duration // Time new members have to pass the captcha
+
+	Filters *distconf.Struct // Anti-spam filters (FiltersConfig)
+
+	RaidJoins       *distconf.Int      // Joins within RaidWindow that lock a chat down, disabled when 0
+	RaidWindow      *distconf.Duration // Window the joins are counted in
+	RaidQuietPeriod *distconf.Duration // Time without joins after which the lockdown is lifted
+
+	AuditPath       string        // File the audit log is appended to
+	AuditChannel    *distconf.Int // Staff channel mirroring the audit log, disabled when 0
+	AuditMaxEntries *distconf.Int // Audit entries per file, the file is rotated once full
+
+	AnnounceUnmutes *distconf.Bool // Announce on the chat when a temporary mute expires
+
+	StaffChat          *distconf.Int      // Chat the reports are sent to, sent to the admins privately when 0
+	ReportCooldown     *distconf.Duration // Time between the reports of a user
+	ReportMuteDuration *distconf.Str      // Duration of the mutes from the reports, like "1h"
 }
 
-var AppConfig *config
+var (
+	AppConfig *config
+
+	dconf     *distconf.Distconf
+	refresher *distconf.Refresher
+)
+
+// Chat returns the settings of chatId and whether it is in the allow-list.
+func (c *config) Chat(chatId int64) (ChatConfig, bool) {
+	for _, chat := range c.Chats.Get().([]ChatConfig) {
+		if chat.ID == chatId {
+			return chat, true
+		}
+	}
+	return defaultChatConfig, false
+}
+
+// WarnThresholds returns the warning escalation of the chat.
+func (c *config) WarnThresholds(chatId int64) []WarnThreshold {
+	chat, _ := c.Chat(chatId)
+	thresholds := chat.Warnings
+	if len(thresholds) == 0 {
+		thresholds = c.DefaultWarnThresholds.Get().([]WarnThreshold)
+	}
+	// Callers may sort them
+	return append([]WarnThreshold(nil), thresholds...)
+}
+
+// Captcha returns the captcha mode of the chat.
+func (c *config) Captcha(chatId int64) string {
+	if chat, _ := c.Chat(chatId); chat.Captcha != "" {
+		return chat.Captcha
+	}
+	return c.CaptchaMode.Get()
+}
 
 // Configuration will be read from top to bottom of the readers list.
 func LoadConfig(log distconf.Logger) {
 	jconf := distconf.JSONConfig{}
-	jconf.RefreshFile(configPath)
+	if err := jconf.RefreshFile(configPath); err != nil {
+		log(configPath, err, "Unable to read config file")
+	}
 	readers := []distconf.Reader{&jconf} // &distconf.Env{}
 	d := &distconf.Distconf{Logger: log, Readers: readers}
+	dconf = d
 
 	AppConfig = &config{
-		BotToken:   d.Str("BotToken", "invalid:token").Get(),
-		ApiBaseUrl: d.Str("ApiBaseUrl", "http://127.0.0.1/").Get(),
+		BotToken:    d.Str("BotToken", "invalid:token").Get(),
+		ApiEndpoint: d.Str("ApiEndpoint", tgbotapi.APIEndpoint).Get(),
+		ApiBaseUrl:  d.Str("ApiBaseUrl", "http://127.0.0.1/").Get(),
+
+		ApiTimeout:   d.Duration("ApiTimeout", 10*time.Second).Get(),
+		ApiUserAgent: d.Str("ApiUserAgent", "HebeBot (+https://hestingames.nat.cu)").Get(),
+		ApiToken:     d.Str("ApiToken", "").Get(),
+		ApiKeyHeader: d.Str("ApiKeyHeader", "X-Api-Key").Get(),
+		ApiKey:       d.Str("ApiKey", "").Get(),
+
+		ApiCAFile:             d.Str("ApiCAFile", "").Get(),
+		ApiCertFingerprint:    d.Str("ApiCertFingerprint", "").Get(),
+		ApiClientCertFile:     d.Str("ApiClientCertFile", "").Get(),
+		ApiClientKeyFile:      d.Str("ApiClientKeyFile", "").Get(),
+		ApiInsecureSkipVerify: d.Bool("ApiInsecureSkipVerify", false).Get(),
+
+		ApiMaxRetries:      d.Int("ApiMaxRetries", 2),
+		ApiRetryBaseDelay:  d.Duration("ApiRetryBaseDelay", 250*time.Millisecond),
+		ApiRetryMaxDelay:   d.Duration("ApiRetryMaxDelay", 2*time.Second),
+		ApiBreakerFailures: d.Int("ApiBreakerFailures", 5),
+		ApiBreakerCooldown: d.Duration("ApiBreakerCooldown", 30*time.Second),
+		ApiCacheTTL:        d.Duration("ApiCacheTTL", 10*time.Second),
+		ApiCacheMaxStale:   d.Duration("ApiCacheMaxStale", 5*time.Minute),
+
+		CallbackSecret: d.Str("CallbackSecret", "").Get(),
+
+		UpdateMode:      d.Str("UpdateMode", UpdateModePolling).Get(),
+		WebhookUrl:      d.Str("WebhookUrl", "").Get(),
+		WebhookListen:   d.Str("WebhookListen", ":8443").Get(),
+		WebhookSecret:   d.Str("WebhookSecret", "").Get(),
+		WebhookCertFile: d.Str("WebhookCertFile", "").Get(),
+		WebhookKeyFile:  d.Str("WebhookKeyFile", "").Get(),
+
+		StatePath:       d.Str("StatePath", "hebe-state.json").Get(),
+		ShutdownTimeout: d.Duration("ShutdownTimeout", 10*time.Second),
+		WorkerPoolSize:  d.Int("WorkerPoolSize", 8).Get(),
+		WorkerQueueSize: d.Int("WorkerQueueSize", 64).Get(),
+		MetricsListen:   d.Str("MetricsListen", "").Get(),
+		SendGlobalRate:  d.Int("SendGlobalRate", 30).Get(),
+		SendGroupRate:   d.Int("SendGroupRate", 20).Get(),
+		SendMaxRetries:  d.Int("SendMaxRetries", 5).Get(),
+
+		Chats:              d.Struct("Chats", []ChatConfig{}),
+		AllowPrivate:       d.Bool("AllowPrivate", true),
+		UnauthorizedAction: d.Str("UnauthorizedAction", UnauthorizedReply),
+		InlinePolicy:       d.Str("InlinePolicy", InlineMembers),
+		InlineCacheTime:    d.Int("InlineCacheTime", 10),
+
+		Owners:        d.Struct("Owners", []int64{}),
+		AdminCacheTTL: d.Duration("AdminCacheTTL", 10*time.Minute),
+
+		DefaultWarnThresholds: d.Struct("DefaultWarnThresholds", []WarnThreshold{
+			{Warns: 3, Action: "mute", Duration: "24h"},
+			{Warns: 5, Action: "ban"},
+		}),
+
+		CaptchaMode:    d.Str("CaptchaMode", CaptchaButton),
+		CaptchaTimeout: d.Duration("CaptchaTimeout", 3*time.Minute),
+
+		Filters: d.Struct("Filters", FiltersConfig{
+			Links:         FilterConfig{Action: FilterDelete},
+			LinkWhitelist: []string{"hestingames.nat.cu", "t.me/hestingames"},
+			Forwards:      FilterConfig{Action: FilterDelete},
+			MaxEmoji:      10,
+			CapsMinLength: 10,
+			CapsMaxRatio:  0.7,
+			Flood:         FilterConfig{Action: FilterMute},
+			FloodMessages: 6,
+			FloodWindow:   "10s",
+			MuteDuration:  "1h",
+		}),
+
+		RaidJoins:       d.Int("RaidJoins", 15),
+		RaidWindow:      d.Duration("RaidWindow", time.Minute),
+		RaidQuietPeriod: d.Duration("RaidQuietPeriod", 10*time.Minute),
+
+		AuditPath:       d.Str("AuditPath", "hebe-audit.jsonl").Get(),
+		AuditChannel:    d.Int("AuditChannel", 0),
+		AuditMaxEntries: d.Int("AuditMaxEntries", 5000),
+
+		AnnounceUnmutes: d.Bool("AnnounceUnmutes", false),
+
+		StaffChat:          d.Int("StaffChat", 0),
+		ReportCooldown:     d.Duration("ReportCooldown", time.Minute),
+		ReportMuteDuration: d.Str("ReportMuteDuration", "1h"),
+	}
+
+	// Reload the config file periodically so the dynamic settings can be
+	// changed without restarting the bot
+	refresher = &distconf.Refresher{
+		WaitTime:  d.Duration("ConfigRefreshInterval", 30*time.Second),
+		ToRefresh: &fileRefresher{jconf: &jconf, path: configPath, log: log},
+	}
+	go refresher.Start()
+}
+
+// Close stops reloading the config file and closes the config readers.
+func Close() {
+	if refresher != nil {
+		refresher.Close()
+		<-refresher.Done()
+	}
+	if dconf != nil {
+		dconf.Close()
+	}
+}
+
+type fileRefresher struct {
+	jconf *distconf.JSONConfig
+	path  string
+	log   distconf.Logger
+}
+
+func (f *fileRefresher) Refresh() {
+	if err := f.jconf.RefreshFile(f.path); err != nil {
+		f.log(f.path, err, "Unable to reload config file")
 	}
 }
@@ -0,0 +1,75 @@
+package apiclient
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without making the request while the circuit
+// breaker of the host is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// breaker stops the requests to a host after too many consecutive failures.
+// Once the cooldown is over a single request goes through as a probe, its
+// result closes the breaker or opens it again.
+type breaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	open      bool
+	probing   bool
+}
+
+// allow reports whether a request can be made to the host. When it returns
+// true the caller must report the result with done.
+func (b *breaker) allow(host string, policy Policy) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open || policy.BreakerFailures < 1 {
+		return nil
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return fmt.Errorf("%s: %w", host, ErrCircuitOpen)
+	}
+	b.probing = true
+	return nil
+}
+
+// done records the result of a request and returns whether the breaker
+// opened or closed because of it.
+func (b *breaker) done(failed bool, policy Policy) (changed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasOpen := b.open
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		b.open = false
+		return wasOpen
+	}
+
+	b.failures++
+	if policy.BreakerFailures > 0 && (b.open || b.failures >= policy.BreakerFailures) {
+		b.open = true
+		b.openUntil = time.Now().Add(policy.BreakerCooldown)
+	}
+	return b.open != wasOpen
+}
+
+// release lets another probe through when the probe request was cancelled
+// by the caller, as it says nothing about the host.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *breaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open
+}
@@ -0,0 +1,102 @@
+package apiclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	var failing int32 = 1
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	const cooldown = 50 * time.Millisecond
+	var changes []bool
+	client := New(Options{
+		Policy: func() Policy { return Policy{BreakerFailures: 2, BreakerCooldown: cooldown} },
+		OnBreakerChange: func(host string, open bool) {
+			changes = append(changes, open)
+		},
+	})
+	get := func() error {
+		_, err := client.Do(context.Background(), http.MethodGet, server.URL)
+		return err
+	}
+
+	steps := []struct {
+		name     string
+		wait     time.Duration
+		failing  bool
+		open     bool  // Breaker state after the request
+		attempts int32 // Requests that reached the backend so far
+	}{
+		{"first failure", 0, true, false, 1},
+		{"opens", 0, true, true, 2},
+		{"open, not sent", 0, true, true, 2},
+		{"failed probe opens it again", cooldown, true, true, 3},
+		{"open after the failed probe", 0, false, true, 3},
+		{"probe closes it", cooldown, false, false, 4},
+		{"closed", 0, false, false, 5},
+	}
+	for _, step := range steps {
+		time.Sleep(step.wait)
+		if step.failing {
+			atomic.StoreInt32(&failing, 1)
+		} else {
+			atomic.StoreInt32(&failing, 0)
+		}
+
+		// Requests fail while the backend fails or the breaker is open
+		if err := get(); (err != nil) != (step.failing || step.open) {
+			t.Errorf("%s: unexpected result %v", step.name, err)
+		}
+		if got := client.Degraded(); got != step.open {
+			t.Errorf("%s: breaker open %v, want %v", step.name, got, step.open)
+		}
+		if got := atomic.LoadInt32(&attempts); got != step.attempts {
+			t.Errorf("%s: %d requests sent, want %d", step.name, got, step.attempts)
+		}
+	}
+
+	want := []bool{true, false}
+	if len(changes) != len(want) || changes[0] != want[0] || changes[1] != want[1] {
+		t.Errorf("breaker changes %v, want %v", changes, want)
+	}
+}
+
+func TestBreakerSingleProbe(t *testing.T) {
+	policy := Policy{BreakerFailures: 1, BreakerCooldown: time.Millisecond}
+	b := &breaker{}
+	if err := b.allow("backend", policy); err != nil {
+		t.Fatal(err)
+	}
+	b.done(true, policy)
+	time.Sleep(2 * time.Millisecond)
+
+	// Only one request goes through once the cooldown is over
+	if err := b.allow("backend", policy); err != nil {
+		t.Fatalf("probe not allowed: %v", err)
+	}
+	if err := b.allow("backend", policy); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second request while probing allowed: %v", err)
+	}
+	// A cancelled probe lets another one through
+	b.release()
+	if err := b.allow("backend", policy); err != nil {
+		t.Fatalf("probe after a cancelled one not allowed: %v", err)
+	}
+	if b.done(false, policy) != true || b.isOpen() {
+		t.Error("successful probe did not close the breaker")
+	}
+}
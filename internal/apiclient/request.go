@@ -1,32 +1,169 @@
 package apiclient
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 )
 
-var client = &http.Client{}
+// Bytes of the response body kept in a StatusError
+const errorBodySize = 512
 
-func DoRequest(verb, url string) ([]byte, error) {
-	request, err := http.NewRequest(verb, url, nil)
+// Client makes the requests to an api backend. It is safe for concurrent use.
+type Client struct {
+	http    *http.Client
+	header  http.Header
+	options Options
+
+	breakersMu sync.Mutex
+	breakers   map[string]*breaker // By host
+}
+
+// Options of a Client.
+type Options struct {
+	Timeout   time.Duration     // Timeout of each request, including reading the body. None when 0
+	UserAgent string            // User-Agent header, Go's default when empty
+	Header    http.Header       // Headers sent on every request, like the auth header
+	Transport http.RoundTripper // http.DefaultTransport when nil
+
+	// Policy returns the retry and circuit breaker policy, it is called on
+	// every request so changes apply right away. Single attempts when nil
+	Policy func() Policy
+
+	OnRetry         func(verb, url string, retry int, err error) // Called before each retry
+	OnBreakerChange func(host string, open bool)                 // Called when the breaker of a host opens or closes
+}
+
+// StatusError is returned when the backend answers with a non 2xx status.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string // Beginning of the response body
+}
+
+func (e *StatusError) Error() string {
+	text := fmt.Sprintf("%s %s: http status %d", e.Method, e.URL, e.StatusCode)
+	if e.Body != "" {
+		text += ": " + e.Body
+	}
+	return text
+}
+
+func New(opts Options) *Client {
+	header := opts.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	if opts.UserAgent != "" {
+		header.Set("User-Agent", opts.UserAgent)
+	}
+	return &Client{
+		http:     &http.Client{Timeout: opts.Timeout, Transport: opts.Transport},
+		header:   header,
+		options:  opts,
+		breakers: make(map[string]*breaker),
+	}
+}
+
+// Do sends the request and returns the response body. Idempotent requests
+// failing because of the backend are retried with backoff, and no request
+// is made while the circuit breaker of the host is open.
+func (c *Client) Do(ctx context.Context, verb, rawUrl string) ([]byte, error) {
+	var policy Policy
+	if c.options.Policy != nil {
+		policy = c.options.Policy()
+	}
+
+	host := rawUrl
+	if u, err := url.Parse(rawUrl); err == nil {
+		host = u.Host
+	}
+	b := c.breaker(host)
+
+	for retry := 0; ; retry++ {
+		if err := b.allow(host, policy); err != nil {
+			return nil, err
+		}
+
+		body, err := c.do(ctx, verb, rawUrl)
+		if err != nil && ctx.Err() != nil {
+			b.release()
+			return nil, err
+		}
+		if b.done(Degraded(err), policy) && c.options.OnBreakerChange != nil {
+			c.options.OnBreakerChange(host, b.isOpen())
+		}
+
+		if err == nil || retry >= policy.MaxRetries || !retryable(ctx, verb, err) || b.isOpen() {
+			return body, err
+		}
+		if c.options.OnRetry != nil {
+			c.options.OnRetry(verb, rawUrl, retry+1, err)
+		}
+		if err := sleep(ctx, backoff(retry, policy)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Degraded reports whether the circuit breaker of any host is open.
+func (c *Client) Degraded() bool {
+	c.breakersMu.Lock()
+	defer c.breakersMu.Unlock()
+	for _, b := range c.breakers {
+		if b.isOpen() {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client) breaker(host string) *breaker {
+	c.breakersMu.Lock()
+	defer c.breakersMu.Unlock()
+	b, ok := c.breakers[host]
+	if !ok {
+		b = &breaker{}
+		c.breakers[host] = b
+	}
+	return b
+}
+
+// do makes a single attempt of the request.
+func (c *Client) do(ctx context.Context, verb, url string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, verb, url, nil)
 	if err != nil {
 		// Internal error
 		return nil, err
 	}
+	for key, values := range c.header {
+		request.Header[key] = values
+	}
 
-	response, err := client.Do(request)
+	response, err := c.http.Do(request)
 	if err != nil {
 		// Internal error
 		return nil, err
 	}
+	defer response.Body.Close()
 
-	if response.StatusCode >= 300 {
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
 		// HTTP error
-		return nil, fmt.Errorf("http code is not 2xx")
+		body, _ := ioutil.ReadAll(io.LimitReader(response.Body, errorBodySize))
+		return nil, &StatusError{
+			Method:     verb,
+			URL:        url,
+			StatusCode: response.StatusCode,
+			Body:       strings.TrimSpace(string(body)),
+		}
 	}
 
-	body, err := ioutil.ReadAll(response.Body)
-	response.Body.Close()
-	return body, err
+	return ioutil.ReadAll(response.Body)
 }
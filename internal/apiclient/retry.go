@@ -0,0 +1,95 @@
+package apiclient
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Policy sets how the failed requests are retried and when the circuit
+// breaker of a host opens. The zero Policy makes a single attempt and never
+// opens the breaker.
+type Policy struct {
+	MaxRetries int           // Retries of a failed idempotent request
+	BaseDelay  time.Duration // Backoff before the first retry, doubled on each retry
+	MaxDelay   time.Duration // Maximum backoff between retries
+
+	BreakerFailures int           // Consecutive failures opening the breaker, disabled when 0
+	BreakerCooldown time.Duration // Time the breaker stays open before a probe request
+}
+
+var (
+	random   = rand.New(rand.NewSource(time.Now().UnixNano()))
+	randomMu sync.Mutex
+)
+
+// Degraded reports whether err comes from the backend being unavailable:
+// the breaker is open, the backend failed with a 5xx status, timed out or
+// could not be reached. Those are worth telling the users about, unlike the
+// errors of a bad request. Certificate errors are not, as they last until
+// the configuration is fixed.
+func Degraded(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	if certificateError(err) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	return (errors.As(err, &opErr) && opErr.Op == "dial") || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// retryable reports whether the request can be made again after err.
+func retryable(ctx context.Context, verb string, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	// Only idempotent requests, the others may have been applied already
+	if verb != http.MethodGet && verb != http.MethodHead {
+		return false
+	}
+	return Degraded(err)
+}
+
+// backoff returns the delay before the retry, using full jitter so the
+// retries of concurrent requests are spread out.
+func backoff(retry int, policy Policy) time.Duration {
+	delay := policy.BaseDelay << uint(retry)
+	if delay <= 0 || (policy.MaxDelay > 0 && delay > policy.MaxDelay) {
+		delay = policy.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	randomMu.Lock()
+	defer randomMu.Unlock()
+	return time.Duration(random.Int63n(int64(delay)))
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,86 @@
+package apiclient
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+)
+
+// timeoutError is a net.Error timing out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestDegraded(t *testing.T) {
+	urlErr := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://backend/query", Err: err}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"circuit open", fmt.Errorf("backend: %w", ErrCircuitOpen), true},
+		{"deadline", urlErr(context.DeadlineExceeded), true},
+		{"5xx", &StatusError{StatusCode: http.StatusBadGateway}, true},
+		{"4xx", &StatusError{StatusCode: http.StatusNotFound}, false},
+		{"timeout", urlErr(timeoutError{}), true},
+		{"dial", urlErr(&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("no route to host")}), true},
+		{"connection refused", urlErr(&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNREFUSED)}), true},
+		{"connection reset", urlErr(&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), false},
+		{"bad address", urlErr(&net.AddrError{Err: "missing port", Addr: "backend"}), false},
+		{"unknown authority", urlErr(x509.UnknownAuthorityError{}), false},
+		{"bad hostname", urlErr(x509.HostnameError{Host: "backend"}), false},
+		{"pin mismatch", urlErr(fmt.Errorf("certificate fingerprint 00: %w", errPinMismatch)), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Degraded(test.err); got != test.want {
+				t.Errorf("Degraded(%v) = %v, want %v", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRetryOnlyIdempotent(t *testing.T) {
+	tests := []struct {
+		verb     string
+		attempts int32
+	}{
+		{http.MethodGet, 3},
+		{http.MethodHead, 3},
+		{http.MethodPost, 1},
+		{http.MethodPut, 1},
+		{http.MethodDelete, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.verb, func(t *testing.T) {
+			var attempts int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&attempts, 1)
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer server.Close()
+
+			client := New(Options{Policy: func() Policy { return Policy{MaxRetries: 2} }})
+			if _, err := client.Do(context.Background(), test.verb, server.URL); err == nil {
+				t.Fatal("request did not fail")
+			}
+			if got := atomic.LoadInt32(&attempts); got != test.attempts {
+				t.Errorf("%d attempts, want %d", got, test.attempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package apiclient
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/hestingames/hg-hebe-bot/internal/environment"
+)
+
+// ErrInsecureInProd is returned when the certificate check is disabled in
+// the production environment.
+var ErrInsecureInProd = errors.New("certificate check cannot be disabled in production")
+
+// errPinMismatch is returned when the backend certificate is not the pinned one
+var errPinMismatch = errors.New("certificate does not match the pinned one")
+
+// TLSOptions sets how a Client trusts the backend and authenticates to it.
+// The zero TLSOptions trusts the system roots only.
+type TLSOptions struct {
+	CAFile      string // PEM bundle trusted along with the system roots
+	Fingerprint string // SHA-256 of the backend certificate in hex, trusted instead of any CA
+	CertFile    string // Client certificate for mutual TLS
+	KeyFile     string // Key of the client certificate
+	Insecure    bool   // Skip the certificate check, refused in production
+}
+
+// NewTransport returns a transport with the TLS options applied on a copy
+// of http.DefaultTransport, which is left untouched.
+func NewTransport(opts TLSOptions) (*http.Transport, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if opts.CAFile != "" {
+		pem, err := ioutil.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA bundle: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil || roots == nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.CAFile)
+		}
+		config.RootCAs = roots
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	switch {
+	case opts.Fingerprint != "":
+		pin, err := parseFingerprint(opts.Fingerprint)
+		if err != nil {
+			return nil, err
+		}
+		// The pinned certificate is usually self signed, so the chain is not
+		// checked, the certificate itself is
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return fmt.Errorf("no certificate presented: %w", errPinMismatch)
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			if subtle.ConstantTimeCompare(sum[:], pin) != 1 {
+				return fmt.Errorf("certificate fingerprint %x: %w", sum, errPinMismatch)
+			}
+			return nil
+		}
+	case opts.Insecure:
+		if environment.IsProd() {
+			return nil, ErrInsecureInProd
+		}
+		config.InsecureSkipVerify = true
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	return transport, nil
+}
+
+// certificateError reports whether err comes from the backend certificate
+// not being trusted.
+func certificateError(err error) bool {
+	var unknownAuthority x509.UnknownAuthorityError
+	var invalid x509.CertificateInvalidError
+	var hostname x509.HostnameError
+	return errors.As(err, &unknownAuthority) || errors.As(err, &invalid) ||
+		errors.As(err, &hostname) || errors.Is(err, errPinMismatch)
+}
+
+// parseFingerprint decodes a SHA-256 fingerprint, with or without the colons
+// openssl prints.
+func parseFingerprint(fingerprint string) ([]byte, error) {
+	pin, err := hex.DecodeString(strings.ReplaceAll(fingerprint, ":", ""))
+	if err != nil || len(pin) != sha256.Size {
+		return nil, fmt.Errorf("invalid certificate fingerprint %q, expected a SHA-256 in hex", fingerprint)
+	}
+	return pin, nil
+}
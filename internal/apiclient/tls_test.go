@@ -0,0 +1,172 @@
+package apiclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hestingames/hg-hebe-bot/internal/environment"
+)
+
+func newTLSServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// get requests the server through a transport built with opts.
+func get(t *testing.T, server *httptest.Server, opts TLSOptions) error {
+	t.Helper()
+	transport, err := NewTransport(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.CloseIdleConnections()
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// writePEM writes the blocks to a file in the test directory.
+func writePEM(t *testing.T, name string, blocks ...*pem.Block) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	var data []byte
+	for _, block := range blocks {
+		data = append(data, pem.EncodeToMemory(block)...)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSystemRoots(t *testing.T) {
+	server := newTLSServer(t)
+	if err := get(t, server, TLSOptions{}); !certificateError(err) {
+		t.Errorf("self signed certificate trusted: %v", err)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	server := newTLSServer(t)
+	sum := sha256.Sum256(server.Certificate().Raw)
+
+	if err := get(t, server, TLSOptions{Fingerprint: hex.EncodeToString(sum[:])}); err != nil {
+		t.Errorf("pinned certificate refused: %v", err)
+	}
+
+	// As printed by openssl
+	colons := strings.ReplaceAll(fmt.Sprintf("% X", sum), " ", ":")
+	if err := get(t, server, TLSOptions{Fingerprint: colons}); err != nil {
+		t.Errorf("pinned certificate with colons refused: %v", err)
+	}
+
+	sum[0] ^= 0xff
+	if err := get(t, server, TLSOptions{Fingerprint: hex.EncodeToString(sum[:])}); !certificateError(err) {
+		t.Errorf("certificate not pinned trusted: %v", err)
+	}
+
+	if _, err := NewTransport(TLSOptions{Fingerprint: "not a fingerprint"}); err == nil {
+		t.Error("invalid fingerprint accepted")
+	}
+}
+
+func TestCAFile(t *testing.T) {
+	server := newTLSServer(t)
+	ca := writePEM(t, "ca.pem", &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	if err := get(t, server, TLSOptions{CAFile: ca}); err != nil {
+		t.Errorf("certificate signed by the CA refused: %v", err)
+	}
+
+	empty := writePEM(t, "empty.pem")
+	if _, err := NewTransport(TLSOptions{CAFile: empty}); err == nil {
+		t.Error("CA bundle without certificates accepted")
+	}
+}
+
+func TestClientCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "hebe"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := writePEM(t, "client.pem", &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyFile := writePEM(t, "client.key", &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	clients := x509.NewCertPool()
+	clients.AddCert(cert)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clients}
+	server.StartTLS()
+	defer server.Close()
+	ca := writePEM(t, "ca.pem", &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	if err := get(t, server, TLSOptions{CAFile: ca, CertFile: certFile, KeyFile: keyFile}); err != nil {
+		t.Errorf("client certificate refused: %v", err)
+	}
+	if err := get(t, server, TLSOptions{CAFile: ca}); err == nil {
+		t.Error("request without client certificate accepted")
+	}
+	if _, err := NewTransport(TLSOptions{CertFile: certFile}); err == nil {
+		t.Error("client certificate without key accepted")
+	}
+}
+
+func TestInsecure(t *testing.T) {
+	defer environment.Set(environment.Environment())
+	server := newTLSServer(t)
+
+	environment.Set("dev")
+	if err := get(t, server, TLSOptions{Insecure: true}); err != nil {
+		t.Errorf("insecure request failed in dev: %v", err)
+	}
+
+	environment.Set("prod")
+	if _, err := NewTransport(TLSOptions{Insecure: true}); err != ErrInsecureInProd {
+		t.Errorf("insecure transport in prod: %v, want %v", err, ErrInsecureInProd)
+	}
+	// Pinning stays allowed, the certificate is still checked
+	sum := sha256.Sum256(server.Certificate().Raw)
+	if _, err := NewTransport(TLSOptions{Insecure: true, Fingerprint: hex.EncodeToString(sum[:])}); err != nil {
+		t.Errorf("pinned transport in prod: %v", err)
+	}
+}
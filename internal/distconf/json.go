@@ -26,20 +26,31 @@ func (j *JSONConfig) RefreshFile(filename string) error {
 	return f.Close()
 }
 
-// Refresh loads the configuration from a Reader
+// Refresh loads the configuration from a Reader. String values are stored
+// unquoted, any other JSON value (objects, arrays, numbers...) is stored as
+// its raw JSON representation so it can back a Struct.
 func (j *JSONConfig) Refresh(input io.Reader) error {
-	fileContents := map[string]string{}
+	fileContents := map[string]json.RawMessage{}
 	if err := json.NewDecoder(input).Decode(&fileContents); err != nil {
 		return err
 	}
-	j.mu.Lock()
 	newVals := make(map[string][]byte, len(fileContents))
 	for k, v := range fileContents {
-		newVals[k] = []byte(v)
+		var str string
+		if err := json.Unmarshal(v, &str); err == nil {
+			newVals[k] = []byte(str)
+		} else {
+			newVals[k] = []byte(v)
+		}
 	}
+	j.mu.Lock()
 	j.vals = newVals
-	j.mu.Unlock()
+	watches := make(map[string][]func(string), len(j.watches))
 	for k, cbs := range j.watches {
+		watches[k] = cbs
+	}
+	j.mu.Unlock()
+	for k, cbs := range watches {
 		for _, cb := range cbs {
 			cb(k)
 		}
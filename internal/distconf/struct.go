@@ -36,7 +36,7 @@ func (s *structConf) Update(newValue []byte) error {
 			s.currentVal.Store(reflect.Indirect(val).Interface())
 		}
 	}
-	if reflect.DeepEqual(oldValue, s.currentVal.Load()) {
+	if !reflect.DeepEqual(oldValue, s.currentVal.Load()) {
 		s.update()
 	}
 	return nil
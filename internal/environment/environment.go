@@ -28,6 +28,12 @@ func Environment() string {
 	return env
 }
 
+// Set overrides the environment read from ENVIRONMENT, so the tests can run
+// as any environment.
+func Set(environment string) {
+	env = environment
+}
+
 func IsDev() bool {
 	return Environment() == dev
 }
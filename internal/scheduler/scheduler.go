@@ -0,0 +1,276 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hestingames/hg-hebe-bot/internal/store"
+)
+
+// Store key of the scheduled jobs
+const jobsKey = "scheduler.jobs"
+
+// Failed jobs are retried after retryBaseDelay, doubled on each failure up
+// to retryMaxDelay
+var (
+	retryBaseDelay = 30 * time.Second
+	retryMaxDelay  = time.Hour
+)
+
+// Job is a task to be run at a given time. Jobs are persisted, so they are
+// still run if the bot is restarted before they are due, as soon as it
+// starts again.
+type Job struct {
+	ID   string          // Unique id, scheduling a job with the same id replaces it
+	Kind string          // Selects the handler running the job
+	At   time.Time       // When the job is due
+	Data json.RawMessage // Job parameters, decoded by the handler
+
+	Attempts int // Failed runs so far
+}
+
+// NewJob returns a job with data JSON encoded.
+func NewJob(id, kind string, at time.Time, data interface{}) (Job, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Job{}, err
+	}
+	return Job{ID: id, Kind: kind, At: at, Data: raw}, nil
+}
+
+// Decode decodes the job data into v.
+func (j Job) Decode(v interface{}) error {
+	return json.Unmarshal(j.Data, v)
+}
+
+// Handler runs a job. Failed jobs are retried with backoff until they
+// succeed, jobs that can never succeed should not return an error.
+type Handler func(Job) error
+
+// Scheduler runs the persisted jobs when they are due, one at a time.
+type Scheduler struct {
+	st *store.Store
+
+	mu       sync.Mutex
+	jobs     map[string]Job
+	handlers map[string]Handler
+
+	wake      chan struct{}
+	quit      chan struct{}
+	done      chan struct{}
+	startOnce sync.Once
+	closeOnce sync.Once
+}
+
+// New loads the jobs persisted in st. Jobs are not run until Start is called,
+// so the handlers can be registered first.
+func New(st *store.Store) (*Scheduler, error) {
+	s := &Scheduler{
+		st:       st,
+		jobs:     make(map[string]Job),
+		handlers: make(map[string]Handler),
+		wake:     make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if _, err := st.Get(jobsKey, &s.jobs); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Handle sets the function running the jobs of kind. Jobs of a kind without
+// handler are dropped when due.
+func (s *Scheduler) Handle(kind string, handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[kind] = handler
+}
+
+// Schedule adds the job, replacing any job with the same id.
+func (s *Scheduler) Schedule(job Job) error {
+	s.mu.Lock()
+	s.jobs[job.ID] = job
+	err := s.save()
+	s.mu.Unlock()
+
+	s.notify()
+	return err
+}
+
+// Cancel removes the job with the id, if any.
+func (s *Scheduler) Cancel(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[id]; !ok {
+		return nil
+	}
+	delete(s.jobs, id)
+	return s.save()
+}
+
+// Get returns the job with the id.
+func (s *Scheduler) Get(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	return job, ok
+}
+
+// Jobs returns the scheduled jobs of kind, the earliest first.
+func (s *Scheduler) Jobs(kind string) []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var jobs []Job
+	for _, job := range s.jobs {
+		if job.Kind == kind {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].At.Before(jobs[j].At) })
+	return jobs
+}
+
+// Start runs the jobs as they become due. Jobs that were due while the bot
+// was stopped are run right away.
+func (s *Scheduler) Start() {
+	s.startOnce.Do(func() {
+		go s.run()
+	})
+}
+
+// Close stops running jobs, waiting for the running one to finish. Pending
+// jobs are kept for the next start.
+func (s *Scheduler) Close() {
+	s.closeOnce.Do(func() {
+		close(s.quit)
+		s.startOnce.Do(func() {
+			close(s.done)
+		})
+		<-s.done
+	})
+}
+
+func (s *Scheduler) run() {
+	defer close(s.done)
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		for _, job := range s.due() {
+			select {
+			case <-s.quit:
+				return
+			default:
+			}
+			s.runJob(job)
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(s.untilNext())
+
+		select {
+		case <-s.quit:
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *Scheduler) runJob(job Job) {
+	s.mu.Lock()
+	// The job may have been cancelled or replaced since it was found due
+	if current, ok := s.jobs[job.ID]; !ok || !current.At.Equal(job.At) {
+		s.mu.Unlock()
+		return
+	}
+	handler, ok := s.handlers[job.Kind]
+	s.mu.Unlock()
+
+	var err error
+	if ok {
+		err = handler(job)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The handler may have scheduled the job again
+	if current, ok := s.jobs[job.ID]; !ok || current.Kind != job.Kind || !current.At.Equal(job.At) {
+		return
+	}
+	if err == nil {
+		delete(s.jobs, job.ID)
+	} else {
+		job.Attempts++
+		job.At = time.Now().Add(retryDelay(job.Attempts))
+		s.jobs[job.ID] = job
+	}
+	s.save()
+}
+
+// retryDelay returns how long to wait before running again a job that
+// failed attempts times.
+func retryDelay(attempts int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempts && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay
+}
+
+// due returns the jobs already due, the earliest first.
+func (s *Scheduler) due() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var jobs []Job
+	for _, job := range s.jobs {
+		if !job.At.After(now) {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].At.Before(jobs[j].At) })
+	return jobs
+}
+
+// untilNext returns how long until the next job is due.
+func (s *Scheduler) untilNext() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := time.Hour
+	for _, job := range s.jobs {
+		if wait := time.Until(job.At); wait < next {
+			next = wait
+		}
+	}
+	if next < 0 {
+		next = 0
+	}
+	return next
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// save persists the jobs. Must be called with mu held.
+func (s *Scheduler) save() error {
+	return s.st.Put(jobsKey, s.jobs)
+}
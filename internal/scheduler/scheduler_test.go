@@ -0,0 +1,201 @@
+package scheduler
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hestingames/hg-hebe-bot/internal/store"
+)
+
+func newScheduler(t *testing.T, path string) *Scheduler {
+	t.Helper()
+	st, err := store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	s, err := New(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// recorder records the ids of the jobs run
+type recorder struct {
+	mu  sync.Mutex
+	ids []string
+	ran chan string
+}
+
+func newRecorder() *recorder {
+	return &recorder{ran: make(chan string, 100)}
+}
+
+func (r *recorder) handle(job Job) error {
+	r.mu.Lock()
+	r.ids = append(r.ids, job.ID)
+	r.mu.Unlock()
+	r.ran <- job.ID
+	return nil
+}
+
+func (r *recorder) wait(t *testing.T, n int) []string {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-r.ran:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%d jobs run, want %d", i, n)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.ids...)
+}
+
+func schedule(t *testing.T, s *Scheduler, id, kind string, at time.Time) {
+	t.Helper()
+	job, err := NewJob(id, kind, at, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Schedule(job); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDueOrder(t *testing.T) {
+	s := newScheduler(t, filepath.Join(t.TempDir(), "state.json"))
+	r := newRecorder()
+	s.Handle("test", r.handle)
+
+	now := time.Now()
+	schedule(t, s, "third", "test", now.Add(60*time.Millisecond))
+	schedule(t, s, "first", "test", now.Add(-time.Minute))
+	schedule(t, s, "second", "test", now.Add(-time.Second))
+	s.Start()
+
+	ids := r.wait(t, 3)
+	want := []string{"first", "second", "third"}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("jobs run in order %v, want %v", ids, want)
+		}
+	}
+	if len(s.Jobs("test")) != 0 {
+		t.Error("jobs kept once run")
+	}
+}
+
+func TestCancelAndReplace(t *testing.T) {
+	s := newScheduler(t, filepath.Join(t.TempDir(), "state.json"))
+	r := newRecorder()
+	s.Handle("test", r.handle)
+
+	at := time.Now().Add(30 * time.Millisecond)
+	schedule(t, s, "cancelled", "test", at)
+	schedule(t, s, "replaced", "test", at)
+	schedule(t, s, "last", "test", at.Add(60*time.Millisecond))
+	if err := s.Cancel("cancelled"); err != nil {
+		t.Fatal(err)
+	}
+	// The replacement is due after the last job
+	schedule(t, s, "replaced", "test", at.Add(120*time.Millisecond))
+	s.Start()
+
+	ids := r.wait(t, 2)
+	if len(ids) != 2 || ids[0] != "last" || ids[1] != "replaced" {
+		t.Errorf("jobs run %v, want [last replaced]", ids)
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	st, err := store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedule(t, s, "pending", "test", time.Now().Add(-time.Second))
+	// Never started, as if the bot stopped before the job was due
+	s.Close()
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := newScheduler(t, path)
+	r := newRecorder()
+	restarted.Handle("test", r.handle)
+	if _, ok := restarted.Get("pending"); !ok {
+		t.Fatal("job not loaded")
+	}
+	restarted.Start()
+	if ids := r.wait(t, 1); ids[0] != "pending" {
+		t.Errorf("jobs run %v, want [pending]", ids)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	defer func(base, max time.Duration) {
+		retryBaseDelay, retryMaxDelay = base, max
+	}(retryBaseDelay, retryMaxDelay)
+	retryBaseDelay, retryMaxDelay = 10*time.Millisecond, 40*time.Millisecond
+
+	s := newScheduler(t, filepath.Join(t.TempDir(), "state.json"))
+	attempts := make(chan Job, 10)
+	s.Handle("test", func(job Job) error {
+		attempts <- job
+		if job.Attempts < 3 {
+			return errors.New("telegram down")
+		}
+		return nil
+	})
+	schedule(t, s, "flaky", "test", time.Now())
+	s.Start()
+
+	var last time.Time
+	for i := 0; i < 4; i++ {
+		select {
+		case job := <-attempts:
+			if job.Attempts != i {
+				t.Fatalf("run %d with %d attempts", i, job.Attempts)
+			}
+			if i > 0 && !job.At.After(last) {
+				t.Errorf("retry %d not delayed", i)
+			}
+			last = job.At
+		case <-time.After(2 * time.Second):
+			t.Fatalf("job run %d times, want 4", i)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := s.Get("flaky"); ok {
+		t.Error("job kept once it succeeded")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{100, time.Hour},
+	}
+	for _, test := range tests {
+		if got := retryDelay(test.attempts); got != test.want {
+			t.Errorf("retryDelay(%d) = %s, want %s", test.attempts, got, test.want)
+		}
+	}
+}
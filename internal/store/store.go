@@ -0,0 +1,209 @@
+package store
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Writes within flushDelay of each other are flushed to disk together
+const flushDelay = time.Second
+
+// Store is a small persistent key/value store backed by a JSON file. Values
+// are JSON encoded and the whole file is rewritten atomically, so it is
+// always consistent even if the bot is killed.
+//
+// Writes are batched: they are flushed at most flushDelay after they are
+// made, so a crash loses the last second of writes at most. The file is
+// rewritten entirely on every flush, so the store is meant for small state,
+// append-only logs belong in their own file.
+type Store struct {
+	path string
+	mu   sync.RWMutex
+	data map[string]json.RawMessage
+
+	dirty    bool          // Writes not flushed yet, guarded by mu
+	flushErr error         // Error of the last flush, guarded by mu
+	flushMu  sync.Mutex    // Serializes the flushes
+	kick     chan struct{} // Signals the flusher there are writes
+	quit     chan struct{}
+	stopped  chan struct{}
+	once     sync.Once
+}
+
+// Open loads the store kept at path. A missing file is an empty store. The
+// store must be closed to flush the last writes.
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path:    path,
+		data:    make(map[string]json.RawMessage),
+		kick:    make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	bytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		go s.flusher()
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bytes) != 0 {
+		if err := json.Unmarshal(bytes, &s.data); err != nil {
+			return nil, err
+		}
+	}
+	go s.flusher()
+	return s, nil
+}
+
+// Get decodes the value of key into v. It returns false if the key does not exist.
+func (s *Store) Get(key string, v interface{}) (bool, error) {
+	s.mu.RLock()
+	raw, ok := s.data[key]
+	s.mu.RUnlock()
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+// Put stores v under key. It is written to disk on the next flush, the
+// error of a previous flush is returned if it failed.
+func (s *Store) Put(key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.data[key] = raw
+	s.dirty = true
+	s.mu.Unlock()
+	return s.changed()
+}
+
+// Delete removes key from the store.
+func (s *Store) Delete(key string) error {
+	s.mu.Lock()
+	if _, ok := s.data[key]; !ok {
+		s.mu.Unlock()
+		return nil
+	}
+	delete(s.data, key)
+	s.dirty = true
+	s.mu.Unlock()
+	return s.changed()
+}
+
+// Keys returns the sorted keys starting with prefix.
+func (s *Store) Keys(prefix string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []string
+	for key := range s.data {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Flush writes the pending writes to disk right away.
+func (s *Store) Flush() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	bytes, err := json.Marshal(s.data)
+	s.dirty = false
+	s.mu.Unlock()
+	if err == nil {
+		err = s.write(bytes)
+	}
+
+	s.mu.Lock()
+	if err != nil {
+		// Retried on the next flush
+		s.dirty = true
+	}
+	s.flushErr = err
+	s.mu.Unlock()
+	return err
+}
+
+// Close stops the background flushes and flushes the pending writes.
+func (s *Store) Close() error {
+	s.once.Do(func() { close(s.quit) })
+	<-s.stopped
+	return s.Flush()
+}
+
+// changed wakes the flusher up and returns the last flush error.
+func (s *Store) changed() error {
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.flushErr
+}
+
+// flusher flushes the writes made within flushDelay together.
+func (s *Store) flusher() {
+	defer close(s.stopped)
+	for {
+		select {
+		case <-s.kick:
+		case <-s.quit:
+			return
+		}
+
+		timer := time.NewTimer(flushDelay)
+		select {
+		case <-timer.C:
+		case <-s.quit:
+			timer.Stop()
+			return
+		}
+		s.Flush()
+	}
+}
+
+// write writes bytes to a temporary file and renames it over the previous
+// one.
+func (s *Store) write(bytes []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(bytes); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
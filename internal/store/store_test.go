@@ -0,0 +1,67 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreBatchesWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := s.Put(fmt.Sprintf("key.%d", i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Delete("key.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if keys := reopened.Keys("key."); len(keys) != 99 {
+		t.Fatalf("got %d keys, want 99", len(keys))
+	}
+	var v int
+	if ok, err := reopened.Get("key.42", &v); !ok || err != nil || v != 42 {
+		t.Errorf("Get(key.42) = %d, %v, %v", v, ok, err)
+	}
+	if ok, _ := reopened.Get("key.0", &v); ok {
+		t.Error("deleted key is back")
+	}
+}
+
+func TestStoreFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.Put("key", "value")
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read while the first store is still open
+	other, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	var v string
+	if ok, _ := other.Get("key", &v); !ok || v != "value" {
+		t.Errorf("Get(key) = %q, %v after Flush", v, ok)
+	}
+}
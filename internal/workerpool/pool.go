@@ -0,0 +1,119 @@
+package workerpool
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Pool runs jobs concurrently on a fixed number of workers. Jobs submitted
+// with the same key are always run by the same worker, so they are handled
+// in the order they were submitted.
+type Pool struct {
+	queues []chan func()
+	wg     sync.WaitGroup
+	once   sync.Once
+
+	stopped   int32 // Set once queued jobs must be dropped
+	queued    int64 // Jobs waiting in the queues
+	running   int64 // Jobs being run
+	completed int64 // Jobs finished
+	dropped   int64 // Jobs dropped by Stop
+	blocked   int64 // Submits that had to wait for room in a full queue
+	blockedNs int64 // Total time spent waiting on full queues
+}
+
+// New starts a pool of workers, each one with a queue of queueSize jobs.
+func New(workers, queueSize int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	p := &Pool{queues: make([]chan func(), workers)}
+	for i := range p.queues {
+		p.queues[i] = make(chan func(), queueSize)
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+	return p
+}
+
+func (p *Pool) work(queue chan func()) {
+	defer p.wg.Done()
+	for job := range queue {
+		atomic.AddInt64(&p.queued, -1)
+		if atomic.LoadInt32(&p.stopped) == 1 {
+			atomic.AddInt64(&p.dropped, 1)
+			continue
+		}
+		atomic.AddInt64(&p.running, 1)
+		job()
+		atomic.AddInt64(&p.running, -1)
+		atomic.AddInt64(&p.completed, 1)
+	}
+}
+
+// Submit queues job on the worker owning key. It blocks while the worker
+// queue is full, applying backpressure to the producer.
+func (p *Pool) Submit(key int64, job func()) {
+	queue := p.queues[uint64(key)%uint64(len(p.queues))]
+	atomic.AddInt64(&p.queued, 1)
+
+	select {
+	case queue <- job:
+		return
+	default:
+	}
+
+	start := time.Now()
+	queue <- job
+	atomic.AddInt64(&p.blocked, 1)
+	atomic.AddInt64(&p.blockedNs, int64(time.Since(start)))
+}
+
+// Close stops the workers once every queued job has been run. Submit must
+// not be called after Close.
+func (p *Pool) Close() {
+	p.close()
+	p.wg.Wait()
+}
+
+// Stop drops the queued jobs, the ones already running are left to finish.
+// It does not wait for them, Close does. Submit must not be called after
+// Stop.
+func (p *Pool) Stop() {
+	atomic.StoreInt32(&p.stopped, 1)
+	p.close()
+}
+
+// Dropped returns the number of jobs dropped by Stop so far.
+func (p *Pool) Dropped() int64 {
+	return atomic.LoadInt64(&p.dropped)
+}
+
+func (p *Pool) close() {
+	p.once.Do(func() {
+		for _, queue := range p.queues {
+			close(queue)
+		}
+	})
+}
+
+// Var returns an expvar variable exposing the pool metrics.
+func (p *Pool) Var() expvar.Var {
+	return expvar.Func(func() interface{} {
+		return map[string]interface{}{
+			"workers":   len(p.queues),
+			"queued":    atomic.LoadInt64(&p.queued),
+			"running":   atomic.LoadInt64(&p.running),
+			"completed": atomic.LoadInt64(&p.completed),
+			"dropped":   atomic.LoadInt64(&p.dropped),
+			"blocked":   atomic.LoadInt64(&p.blocked),
+			"blockedMs": time.Duration(atomic.LoadInt64(&p.blockedNs)).Milliseconds(),
+		}
+	})
+}
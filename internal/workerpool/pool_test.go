@@ -0,0 +1,123 @@
+package workerpool
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeyOrder(t *testing.T) {
+	p := New(4, 2)
+
+	var mu sync.Mutex
+	var order []int
+	for i := 0; i < 100; i++ {
+		i := i
+		p.Submit(7, func() {
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+		})
+	}
+	p.Close()
+
+	if len(order) != 100 {
+		t.Fatalf("%d jobs run, want 100", len(order))
+	}
+	for i, job := range order {
+		if job != i {
+			t.Fatalf("job %d run in position %d", job, i)
+		}
+	}
+}
+
+func TestKeysRunConcurrently(t *testing.T) {
+	p := New(2, 1)
+	defer p.Close()
+
+	// Key 0 is blocked until key 1 has run, so they must run on their own workers
+	release := make(chan struct{})
+	done := make(chan struct{})
+	p.Submit(0, func() { <-release })
+	p.Submit(1, func() { close(release) })
+	p.Submit(0, func() { close(done) })
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("a blocked key held the other keys")
+	}
+}
+
+func TestSubmitBlocksWhenFull(t *testing.T) {
+	p := New(1, 1)
+	defer p.Close()
+
+	release := make(chan struct{})
+	p.Submit(0, func() { <-release }) // Running
+	p.Submit(0, func() {})            // Queued, the queue is full
+
+	submitted := make(chan struct{})
+	go func() {
+		p.Submit(0, func() {})
+		close(submitted)
+	}()
+	select {
+	case <-submitted:
+		t.Fatal("Submit did not wait for room in the queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-submitted:
+	case <-time.After(time.Second):
+		t.Fatal("Submit still blocked once the queue had room")
+	}
+}
+
+func TestCloseRunsQueuedJobs(t *testing.T) {
+	p := New(2, 10)
+
+	var run int32
+	for i := 0; i < 20; i++ {
+		p.Submit(int64(i), func() {
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&run, 1)
+		})
+	}
+	p.Close()
+
+	if run != 20 {
+		t.Errorf("%d jobs run before Close returned, want 20", run)
+	}
+}
+
+func TestStopDropsQueuedJobs(t *testing.T) {
+	p := New(1, 10)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var finished, run int32
+	p.Submit(0, func() {
+		close(started)
+		<-release
+		atomic.StoreInt32(&finished, 1)
+	})
+	for i := 0; i < 5; i++ {
+		p.Submit(0, func() { atomic.AddInt32(&run, 1) })
+	}
+	<-started
+
+	p.Stop()
+	close(release)
+	p.Close()
+
+	if finished != 1 {
+		t.Error("running job not left to finish")
+	}
+	if run != 0 || p.Dropped() != 5 {
+		t.Errorf("%d queued jobs run and %d dropped, want 0 and 5", run, p.Dropped())
+	}
+}
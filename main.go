@@ -2,6 +2,10 @@ package main
 
 import (
 	"context"
+	"expvar"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/hestingames/hg-hebe-bot/api"
 	hebe "github.com/hestingames/hg-hebe-bot/bot"
@@ -19,8 +23,18 @@ var (
 )
 
 func main() {
-	ctx = context.Background()
+	// Cancelled on SIGINT/SIGTERM to start the graceful shutdown
+	var stop context.CancelFunc
+	ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// A second signal kills the bot right away
+		<-ctx.Done()
+		stop()
+	}()
+
 	logger = logs.FromContext(ctx)
+	defer logger.Sync() // flushes buffer, if any
 
 	logger.Info("HebeBot - HestinGames")
 
@@ -29,11 +43,63 @@ func main() {
 		logger.Error(msg, zap.Error(err), zap.String("key", key))
 	}
 	config.LoadConfig(logFn)
+	defer config.Close()
 
 	// Initialize CSGO api client
-	api.InitializeCsgoApi(config.AppConfig.ApiBaseUrl)
+	csgo, err := api.NewClient(
+		api.WithBaseURL(config.AppConfig.ApiBaseUrl),
+		api.WithTimeout(config.AppConfig.ApiTimeout),
+		api.WithUserAgent(config.AppConfig.ApiUserAgent),
+		api.WithBearerToken(config.AppConfig.ApiToken),
+		api.WithAPIKey(config.AppConfig.ApiKeyHeader, config.AppConfig.ApiKey),
+		// Development api is using a self signed certificate, pinned or
+		// trusted through its own CA
+		api.WithTLS(api.TLSConfig{
+			CAFile:      config.AppConfig.ApiCAFile,
+			Fingerprint: config.AppConfig.ApiCertFingerprint,
+			CertFile:    config.AppConfig.ApiClientCertFile,
+			KeyFile:     config.AppConfig.ApiClientKeyFile,
+			Insecure:    config.AppConfig.ApiInsecureSkipVerify,
+		}),
+		api.WithPolicy(csgoApiPolicy),
+		api.WithCache(func() api.CachePolicy {
+			return api.CachePolicy{
+				TTL:      config.AppConfig.ApiCacheTTL.Get(),
+				MaxStale: config.AppConfig.ApiCacheMaxStale.Get(),
+			}
+		}),
+		api.WithRetryHook(func(url string, retry int, err error) {
+			logger.Debug("Retrying CSGO api request", zap.String("url", url), zap.Int("retry", retry), zap.Error(err))
+		}),
+		api.WithDegradedHook(func(degraded bool) {
+			if degraded {
+				logger.Warn("CSGO api is down, requests are suspended")
+			} else {
+				logger.Info("CSGO api recovered")
+			}
+		}),
+	)
+	if err != nil {
+		logger.Panic("Unable to initialize CSGO api client", zap.Error(err))
+	}
+	if config.AppConfig.ApiInsecureSkipVerify && config.AppConfig.ApiCertFingerprint == "" {
+		logger.Warn("CSGO api certificate check is disabled")
+	}
+	expvar.Publish("csgoapi", csgo.CacheVar())
 
 	// Initialize Telegram bot
-	hebe.Initialize(logger)
-	hebe.StartBot()
+	hebe.Initialize(logger, csgo)
+	hebe.StartBot(ctx)
+}
+
+// csgoApiPolicy returns the retry and circuit breaker policy of the CSGO api
+// from the live config.
+func csgoApiPolicy() api.Policy {
+	return api.Policy{
+		MaxRetries:      int(config.AppConfig.ApiMaxRetries.Get()),
+		BaseDelay:       config.AppConfig.ApiRetryBaseDelay.Get(),
+		MaxDelay:        config.AppConfig.ApiRetryMaxDelay.Get(),
+		BreakerFailures: int(config.AppConfig.ApiBreakerFailures.Get()),
+		BreakerCooldown: config.AppConfig.ApiBreakerCooldown.Get(),
+	}
 }